@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 
 	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/lsp"
+	"github.com/sgoal/tide/redact"
 	"github.com/sgoal/tide/tool"
 )
 
@@ -18,12 +22,23 @@ type ReActAgent struct {
 	maxLoops  int
 	history   []openaai.ChatCompletionMessage
 	logWriter io.Writer
+	hooks     *Hooks
+	model     string
+	usage     openaai.Usage
+	redactor  *redact.Redactor
+	// languageServers back the lsp tool and report diagnostics to the UI.
+	languageServers *lsp.Manager
 }
 
 const historyFilePath = "conversation_history.json"
 
 func (a *ReActAgent) SaveHistory() error {
-	data, err := json.Marshal(a.history)
+	// History loaded from older sessions may predate redaction.
+	history := make([]openaai.ChatCompletionMessage, len(a.history))
+	for i, msg := range a.history {
+		history[i] = redactMessage(a.redactor, msg)
+	}
+	data, err := json.Marshal(history)
 	if err != nil {
 		return err
 	}
@@ -70,39 +85,56 @@ func NewReActAgent(logWriter io.Writer) (*ReActAgent, error) {
 	if logWriter == nil {
 		logWriter = io.Discard
 	}
+	redactor := newRedactor(logWriter)
+	logWriter = redact.NewWriter(logWriter, redactor)
 
-	return &ReActAgent{
-		client: client,
-		tools: map[string]tool.Tool{
-			"code_writer": &tool.CodeWriterTool{},
-			"file_editor": &tool.FileEditorTool{},
-			"terminal":    &tool.TerminalTool{},
-			"search":      &tool.SearchTool{},
+	// Edit tools require files to have been read with file_reader first
+	reads := &tool.ReadTracker{}
+	format := formatConfig(logWriter)
+	languageServers := lspManager(logWriter)
+	tools := map[string]tool.Tool{
+		"code_writer": &tool.CodeWriterTool{Tracker: reads, Format: format},
+		"file_editor": &tool.FileEditorTool{Tracker: reads, Format: format},
+		"file_reader": &tool.FileReaderTool{Tracker: reads},
+		"apply_patch": &tool.ApplyPatchTool{Tracker: reads, Format: format},
+		"glob":        &tool.GlobTool{},
+		"grep":        &tool.GrepTool{},
+		"terminal": &tool.TerminalTool{
+			Sandbox:    sandboxFor("terminal", logWriter),
+			Persistent: os.Getenv("TIDE_PERSISTENT_SHELL") != "",
 		},
-		maxLoops:  10,
-		logWriter: logWriter,
+		"search":      &tool.SearchTool{Backend: searchBackend(logWriter)},
+		"web_fetch":   &tool.WebFetchTool{},
+		"docs_search": &tool.DocsSearchTool{},
+		"view_image":  &tool.ViewImageTool{},
+		"process":     &tool.ProcessManagerTool{Sandbox: sandboxFor("process", logWriter)},
+		"go_test":     &tool.GoTestTool{Sandbox: sandboxFor("go_test", logWriter)},
+		"lint":        &tool.LintTool{Sandbox: sandboxFor("lint", logWriter)},
+		"debugger":    &tool.DebuggerTool{Sandbox: sandboxFor("debugger", logWriter)},
+		"git":         &tool.GitTool{},
+		"lsp":         &tool.LSPTool{Manager: languageServers, Tracker: reads},
+	}
+	registerPlugins(tools, logWriter)
+
+	hooks, err := LoadHooks(DefaultHooksPath())
+	if err != nil {
+		fmt.Fprintf(logWriter, "Error loading hooks: %v\n", err)
+	}
+
+	return &ReActAgent{
+		client:          client,
+		tools:           tools,
+		maxLoops:        10,
+		logWriter:       logWriter,
+		hooks:           hooks,
+		model:           openaai.GPT4o20240806,
+		redactor:        redactor,
+		languageServers: languageServers,
 	}, nil
 }
 
 func getTools() []openaai.Tool {
 	tools := []openaai.Tool{
-		{
-			Type: openaai.ToolTypeFunction,
-			Function: &openaai.FunctionDefinition{
-				Name:        "search",
-				Description: "A tool for searching the web using DuckDuckGo.",
-				Parameters: json.RawMessage(`{
-					"type": "object",
-					"properties": {
-						"query": {
-							"type": "string",
-							"description": "The search query."
-						}
-					},
-					"required": ["query"]
-				}`),
-			},
-		},
 		{
 			Type: openaai.ToolTypeFunction,
 			Function: &openaai.FunctionDefinition{
@@ -132,13 +164,26 @@ func getTools() []openaai.Tool {
 			Type: openaai.ToolTypeFunction,
 			Function: &openaai.FunctionDefinition{
 				Name:        "terminal",
-				Description: "Executes shell commands. Use this to run scripts, execute programs, or perform any other command-line operations. For example, to run a python script, you would use 'python your_script.py'.",
+				Description: "Executes shell commands. Use this to run scripts, execute programs, or perform any other command-line operations. For example, to run a python script, you would use 'python your_script.py'. Commands are killed after a timeout and long output is truncated, with the full output saved to a file.",
 				Parameters: json.RawMessage(`{
 					"type": "object",
 					"properties": {
 						"command": {
 							"type": "string",
 							"description": "The command to execute."
+						},
+						"cwd": {
+							"type": "string",
+							"description": "The working directory to run the command in. Defaults to the current directory."
+						},
+						"env": {
+							"type": "object",
+							"additionalProperties": {"type": "string"},
+							"description": "Extra environment variables for the command."
+						},
+						"timeout_seconds": {
+							"type": "integer",
+							"description": "Kill the command after this many seconds. Defaults to 120."
 						}
 					},
 					"required": ["command"]
@@ -163,14 +208,31 @@ func getTools() []openaai.Tool {
 						},
 						"search_text": {
 							"type": "string",
-							"description": "The text to search for in the file content."
+							"description": "The exact text to search for in the file content. It must match exactly once unless replace_all is set."
 						},
 						"replace_text": {
 							"type": "string",
 							"description": "The text to replace the searched content with."
+						},
+						"replace_all": {
+							"type": "boolean",
+							"description": "Replace every occurrence of search_text instead of requiring a unique match."
+						},
+						"edits": {
+							"type": "array",
+							"description": "Several edits to apply in order, instead of search_text/replace_text. Either all of them are applied or none.",
+							"items": {
+								"type": "object",
+								"properties": {
+									"search_text": {"type": "string"},
+									"replace_text": {"type": "string"},
+									"replace_all": {"type": "boolean"}
+								},
+								"required": ["search_text", "replace_text"]
+							}
 						}
 					},
-					"required": ["dir_path", "file_name", "search_text", "replace_text"]
+					"required": ["dir_path", "file_name"]
 				}`),
 			},
 		},
@@ -180,15 +242,14 @@ func getTools() []openaai.Tool {
 }
 
 // ProcessCommand processes a command using the ReAct framework with native tool calling.
-func (a *ReActAgent) ProcessCommand(command string) (string, error) {
-	a.history = append(a.history, openaai.ChatCompletionMessage{
-		Role:    openaai.ChatMessageRoleUser,
-		Content: command,
-	})
-	tools := getTools()
+// Images, given as data URLs, are attached to the command for vision-capable models.
+func (a *ReActAgent) ProcessCommand(command string, images ...string) (string, error) {
+	a.history = append(a.history, userMessage(a.redactor.Redact(command), images))
+	defer func() { dropImages(a.history) }()
+	tools := append(getTools(), schemaTools(a.tools)...)
 	for i := 0; i < a.maxLoops; i++ {
 		req := openaai.ChatCompletionRequest{
-			Model:    openaai.GPT4o20240806,
+			Model:    a.model,
 			Messages: a.history,
 			Tools:    tools,
 		}
@@ -198,9 +259,12 @@ func (a *ReActAgent) ProcessCommand(command string) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("chat completion error: %w", err)
 		}
+		a.usage.PromptTokens += resp.Usage.PromptTokens
+		a.usage.CompletionTokens += resp.Usage.CompletionTokens
+		a.usage.TotalTokens += resp.Usage.TotalTokens
 
 		respMsg := resp.Choices[0].Message
-		a.history = append(a.history, respMsg)
+		a.history = append(a.history, redactMessage(a.redactor, respMsg))
 
 		if respMsg.ToolCalls == nil {
 			fmt.Fprintln(a.logWriter, "--- Received final answer ---")
@@ -211,13 +275,16 @@ func (a *ReActAgent) ProcessCommand(command string) (string, error) {
 		for _, toolCall := range respMsg.ToolCalls {
 			if tool, exists := a.tools[toolCall.Function.Name]; exists {
 				fmt.Fprintf(a.logWriter, "Executing tool: %s with args: %s\n", toolCall.Function.Name, toolCall.Function.Arguments)
-				observation, err := tool.Execute(json.RawMessage(toolCall.Function.Arguments))
+				observation, err := a.hooks.Execute(tool, toolCall.Function.Name, json.RawMessage(toolCall.Function.Arguments))
 				if err != nil {
-					observation = fmt.Sprintf("Error executing tool: %v", err)
+					// Keep any output of the failed call, such as the partial
+					// output of a command that timed out.
+					observation = strings.TrimSpace(fmt.Sprintf("Error executing tool: %v\n\n%s", err, observation))
 				}
 				if observation == "" {
 					observation = "No result found."
 				}
+				observation = a.redactor.Redact(observation)
 				fmt.Fprintf(a.logWriter, "Observation: %s\n", observation)
 				a.history = append(a.history, openaai.ChatCompletionMessage{
 					Role:       openaai.ChatMessageRoleTool,
@@ -229,6 +296,9 @@ func (a *ReActAgent) ProcessCommand(command string) (string, error) {
 				fmt.Fprintf(a.logWriter, "Tool '%s' not found.\n", toolCall.Function.Name)
 			}
 		}
+		if msg, ok := toolImagesMessage(a.tools); ok {
+			a.history = append(a.history, msg)
+		}
 	}
 
 	return "", fmt.Errorf("max loops reached")
@@ -237,3 +307,55 @@ func (a *ReActAgent) ProcessCommand(command string) (string, error) {
 func (a *ReActAgent) GetHistory() []openaai.ChatCompletionMessage {
 	return a.history
 }
+
+// ClearHistory forgets the conversation so far.
+func (a *ReActAgent) ClearHistory() {
+	a.history = nil
+}
+
+// UndoLastExchange removes the last user message and everything the agent
+// produced in response to it. It reports whether anything was removed.
+func (a *ReActAgent) UndoLastExchange() bool {
+	for i := len(a.history) - 1; i >= 0; i-- {
+		if isUserPrompt(a.history[i]) {
+			a.history = a.history[:i]
+			return true
+		}
+	}
+	return false
+}
+
+// Model returns the model used for chat completions.
+func (a *ReActAgent) Model() string {
+	return a.model
+}
+
+// SetModel changes the model used for chat completions.
+func (a *ReActAgent) SetModel(model string) {
+	a.model = model
+}
+
+// Tools returns the tools available to the agent, sorted by name.
+func (a *ReActAgent) Tools() []tool.Tool {
+	names := make([]string, 0, len(a.tools))
+	for name := range a.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tools := make([]tool.Tool, 0, len(names))
+	for _, name := range names {
+		tools = append(tools, a.tools[name])
+	}
+	return tools
+}
+
+// Close ends the session, stopping any background processes the agent started.
+func (a *ReActAgent) Close() error {
+	return closeTools(a.tools)
+}
+
+// Usage returns the tokens used by the agent since it was created.
+func (a *ReActAgent) Usage() openaai.Usage {
+	return a.usage
+}
@@ -0,0 +1,13 @@
+package agent
+
+import "github.com/sgoal/tide/tool"
+
+// OnApproval sets the function asked to approve risky operations, such as a
+// force-push or discarding uncommitted changes. It is called from the
+// goroutine running the agent and blocks it until the user answers. Without
+// it those operations are refused.
+func (a *ReActAgent) OnApproval(fn func(question string) bool) {
+	if git, ok := a.tools["git"].(*tool.GitTool); ok {
+		git.SetApprover(fn)
+	}
+}
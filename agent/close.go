@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"errors"
+	"io"
+
+	"github.com/sgoal/tide/tool"
+)
+
+// closeTools releases the resources held by tools, such as background
+// processes, at the end of a session.
+func closeTools(tools map[string]tool.Tool) error {
+	var errs []error
+	for _, t := range tools {
+		if closer, ok := t.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,18 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sgoal/tide/tool"
+)
+
+// formatConfig returns the user's format configuration for the edit tools. A
+// broken configuration falls back to the built-in checks.
+func formatConfig(logWriter io.Writer) *tool.FormatConfig {
+	config, err := tool.LoadFormatConfig(tool.DefaultFormatPath())
+	if err != nil {
+		fmt.Fprintf(logWriter, "Error loading format config, using the built-in checks: %v\n", err)
+	}
+	return config
+}
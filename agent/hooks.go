@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sgoal/tide/tool"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// Hook is a shell command run before or after a tool call. The call details
+// are written to the command's stdin as JSON.
+type Hook struct {
+	// Tool is the name of the tool the hook applies to. Empty or "*" matches
+	// every tool.
+	Tool           string `json:"tool,omitempty"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// Hooks holds the user's hook configuration.
+//
+// A pre_tool_call hook that exits non-zero vetoes the call and its output is
+// returned to the model as the reason. Anything a hook prints on success is
+// appended to the observation.
+type Hooks struct {
+	PreToolCall  []Hook `json:"pre_tool_call,omitempty"`
+	PostToolCall []Hook `json:"post_tool_call,omitempty"`
+}
+
+// hookEvent is the payload written to a hook's stdin.
+type hookEvent struct {
+	Event       string          `json:"event"`
+	Tool        string          `json:"tool"`
+	Arguments   json.RawMessage `json:"arguments"`
+	Observation string          `json:"observation,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// DefaultHooksPath returns the path of the hooks configuration file. It can be
+// overridden with the TIDE_HOOKS_FILE environment variable.
+func DefaultHooksPath() string {
+	if path := os.Getenv("TIDE_HOOKS_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "hooks.json")
+}
+
+// LoadHooks reads the hooks configuration from path. A missing file yields no hooks.
+func LoadHooks(path string) (*Hooks, error) {
+	hooks := &Hooks{}
+	if path == "" {
+		return hooks, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hooks, nil
+		}
+		return hooks, err
+	}
+	if err := json.Unmarshal(data, hooks); err != nil {
+		return &Hooks{}, fmt.Errorf("invalid hooks file %s: %w", path, err)
+	}
+	return hooks, nil
+}
+
+// Execute runs t with args, surrounded by the matching pre and post hooks.
+func (h *Hooks) Execute(t tool.Tool, name string, args json.RawMessage) (string, error) {
+	if h == nil {
+		return t.Execute(args)
+	}
+
+	var notes []string
+	for _, hook := range h.PreToolCall {
+		if !hook.matches(name) {
+			continue
+		}
+		output, err := hook.run(hookEvent{Event: "pre_tool_call", Tool: name, Arguments: args})
+		if err != nil {
+			if output == "" {
+				output = err.Error()
+			}
+			return "", fmt.Errorf("tool call blocked by hook: %s", output)
+		}
+		if output != "" {
+			notes = append(notes, output)
+		}
+	}
+
+	observation, execErr := t.Execute(args)
+
+	event := hookEvent{Event: "post_tool_call", Tool: name, Arguments: args, Observation: observation}
+	if execErr != nil {
+		event.Error = execErr.Error()
+	}
+	for _, hook := range h.PostToolCall {
+		if !hook.matches(name) {
+			continue
+		}
+		output, err := hook.run(event)
+		if err != nil {
+			output = strings.TrimSpace(fmt.Sprintf("post_tool_call hook failed: %v\n%s", err, output))
+		}
+		if output != "" {
+			notes = append(notes, output)
+		}
+	}
+
+	if len(notes) > 0 {
+		observation = strings.TrimRight(observation, "\n") + "\n\n" + strings.Join(notes, "\n")
+	}
+	return observation, execErr
+}
+
+func (hook Hook) matches(name string) bool {
+	return hook.Tool == "" || hook.Tool == "*" || hook.Tool == name
+}
+
+// run executes the hook command and returns its trimmed combined output.
+func (hook Hook) run(event hookEvent) (string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultHookTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", hook.Command)
+	cmd.Stdin = bytes.NewReader(payload)
+	// Children the hook starts are killed with it, and do not keep it
+	// waiting by holding the output pipe open.
+	tool.KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.CombinedOutput()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The hook succeeded but left a background process running.
+		err = nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("hook timed out after %s", timeout)
+	}
+	return strings.TrimSpace(string(output)), err
+}
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"sort"
+
+	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/tool"
+)
+
+// userMessage builds a user message. When images (data URLs) are given, the
+// message is sent as multi-part content for vision-capable models.
+func userMessage(text string, images []string) openaai.ChatCompletionMessage {
+	if len(images) == 0 {
+		return openaai.ChatCompletionMessage{
+			Role:    openaai.ChatMessageRoleUser,
+			Content: text,
+		}
+	}
+	parts := []openaai.ChatMessagePart{{Type: openaai.ChatMessagePartTypeText, Text: text}}
+	for _, image := range images {
+		parts = append(parts, openaai.ChatMessagePart{
+			Type:     openaai.ChatMessagePartTypeImageURL,
+			ImageURL: &openaai.ChatMessageImageURL{URL: image, Detail: openaai.ImageURLDetailAuto},
+		})
+	}
+	return openaai.ChatCompletionMessage{
+		Role:         openaai.ChatMessageRoleUser,
+		MultiContent: parts,
+	}
+}
+
+// toolImagesMessage collects the images produced by tools during the last
+// turn. Tool messages can only carry text, so the images are sent to the model
+// as a separate user message.
+func toolImagesMessage(tools map[string]tool.Tool) (openaai.ChatCompletionMessage, bool) {
+	var names []string
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var images []string
+	for _, name := range names {
+		if provider, ok := tools[name].(tool.ImageProvider); ok {
+			images = append(images, provider.TakeImages()...)
+		}
+	}
+	if len(images) == 0 {
+		return openaai.ChatCompletionMessage{}, false
+	}
+	msg := userMessage("Images loaded by the tool calls above:", images)
+	msg.Name = toolImagesName
+	return msg, true
+}
+
+// toolImagesName marks the user messages carrying tool images, which are not
+// part of what the user said.
+const toolImagesName = "tool_images"
+
+// isUserPrompt reports whether msg is a message the user wrote.
+func isUserPrompt(msg openaai.ChatCompletionMessage) bool {
+	return msg.Role == openaai.ChatMessageRoleUser && msg.Name != toolImagesName
+}
+
+// dropImages replaces the images in history with a short note. Images are
+// large data URLs, so they are only sent in the turn that uses them instead
+// of with every later request and in the saved history.
+func dropImages(history []openaai.ChatCompletionMessage) {
+	for i := range history {
+		parts := history[i].MultiContent
+		for j := range parts {
+			if parts[j].Type == openaai.ChatMessagePartTypeImageURL {
+				parts[j] = openaai.ChatMessagePart{Type: openaai.ChatMessagePartTypeText, Text: "[image removed after use]"}
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sgoal/tide/lsp"
+)
+
+// lspManager returns a language server manager for the current directory.
+// Servers are only started when the lsp tool first needs them.
+func lspManager(logWriter io.Writer) *lsp.Manager {
+	config, err := lsp.LoadConfig(lsp.DefaultConfigPath())
+	if err != nil {
+		fmt.Fprintf(logWriter, "Error loading lsp config, using the default language servers: %v\n", err)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		root = "."
+	}
+	return lsp.NewManager(root, config)
+}
+
+// Diagnostics returns the latest diagnostics from the agent's language
+// servers.
+func (a *ReActAgent) Diagnostics() []lsp.FileDiagnostics {
+	return a.languageServers.Diagnostics()
+}
+
+// DiagnosticsRoot returns the workspace root that diagnostics paths are
+// relative to.
+func (a *ReActAgent) DiagnosticsRoot() string {
+	return a.languageServers.Root()
+}
+
+// OnDiagnostics sets a function called whenever a language server publishes
+// new diagnostics. It is called from a background goroutine.
+func (a *ReActAgent) OnDiagnostics(fn func()) {
+	a.languageServers.OnDiagnostics(fn)
+}
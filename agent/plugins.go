@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/tool"
+)
+
+// registerPlugins adds the plugins found in the plugin directory to tools.
+// Plugins never replace a built-in tool of the same name.
+func registerPlugins(tools map[string]tool.Tool, logWriter io.Writer) {
+	plugins, err := tool.LoadPlugins(tool.DefaultPluginDir())
+	if err != nil {
+		fmt.Fprintf(logWriter, "Error loading plugins: %v\n", err)
+	}
+	for _, plugin := range plugins {
+		if _, exists := tools[plugin.Name()]; exists {
+			fmt.Fprintf(logWriter, "Plugin '%s' skipped: a tool with that name already exists.\n", plugin.Name())
+			continue
+		}
+		tools[plugin.Name()] = plugin
+	}
+}
+
+// schemaTools returns the function definitions of the tools that provide
+// their own parameter schema, sorted by name.
+func schemaTools(tools map[string]tool.Tool) []openaai.Tool {
+	var names []string
+	for name, t := range tools {
+		if _, ok := t.(tool.SchemaProvider); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var defs []openaai.Tool
+	for _, name := range names {
+		t := tools[name]
+		defs = append(defs, openaai.Tool{
+			Type: openaai.ToolTypeFunction,
+			Function: &openaai.FunctionDefinition{
+				Name:        name,
+				Description: t.Description(),
+				Parameters:  t.(tool.SchemaProvider).Parameters(),
+			},
+		})
+	}
+	return defs
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+
+	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/redact"
+	"github.com/sgoal/tide/solo"
+	"github.com/sgoal/tide/tool"
+)
+
+// newRedactor returns the redactor for an agent: the user's redaction
+// patterns, secret-looking environment variables and the environment
+// variables of the SOLO projects in the workspace.
+func newRedactor(logWriter io.Writer) *redact.Redactor {
+	redactor, err := redact.Default()
+	if err != nil {
+		fmt.Fprintf(logWriter, "Error loading redaction patterns: %v\n", err)
+	}
+	redactor.AddValues(solo.EnvValues()...)
+	// Long tool output is saved to disk in full.
+	tool.SetOutputRedactor(redactor)
+	return redactor
+}
+
+// redactMessage returns a copy of msg with secrets removed from its content
+// and tool call arguments.
+func redactMessage(r *redact.Redactor, msg openaai.ChatCompletionMessage) openaai.ChatCompletionMessage {
+	msg.Content = r.Redact(msg.Content)
+	if msg.ToolCalls != nil {
+		calls := make([]openaai.ToolCall, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			call.Function.Arguments = r.Redact(call.Function.Arguments)
+			calls[i] = call
+		}
+		msg.ToolCalls = calls
+	}
+	return msg
+}
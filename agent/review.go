@@ -0,0 +1,240 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/tool"
+)
+
+// maxDiffBytes caps the diff sent to the model.
+const maxDiffBytes = 100 * 1024
+
+const commitPrompt = `You write git commit messages in the Conventional Commits format.
+
+The first line is "<type>(<optional scope>): <summary>", at most 72 characters, in the imperative mood, without a trailing period. The type is one of feat, fix, refactor, perf, docs, test, build, ci, style or chore. Add "!" after the type or scope for breaking changes.
+
+When the change needs explaining, add a blank line and a body wrapped at 72 characters that says what changed and why, not how. Do not list every file.
+
+Reply with the commit message only, without quotes or code fences.`
+
+const reviewPrompt = `You are a careful senior engineer reviewing a change before it is committed. Report real problems: bugs, crashes, race conditions, security issues, resource leaks, missing error handling, broken edge cases and clear readability problems. Do not report style preferences, and do not praise the change.
+
+Every line of the diff is prefixed with its line number in the new version of the file. Only comment on lines the change adds or modifies.
+
+Reply with a JSON object:
+{
+  "summary": "one or two sentences on the change and its overall quality",
+  "issues": [
+    {
+      "file": "path/to/file.go",
+      "line": 42,
+      "severity": "high" | "medium" | "low",
+      "title": "short description of the problem",
+      "explanation": "why it is a problem and how to fix it",
+      "patch": "a unified diff against the current file that fixes the problem, with --- a/path and +++ b/path headers and a few lines of context, or an empty string"
+    }
+  ]
+}
+Use an empty issues list when the change has no problems.`
+
+// ReviewIssue is a problem found in a code review.
+type ReviewIssue struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Explanation string `json:"explanation"`
+	// Patch is a unified diff that fixes the issue, if the model suggested
+	// one.
+	Patch string `json:"patch"`
+}
+
+// Review is the result of reviewing a diff.
+type Review struct {
+	Summary string        `json:"summary"`
+	Issues  []ReviewIssue `json:"issues"`
+}
+
+var severityOrder = map[string]int{"high": 0, "medium": 1, "low": 2}
+
+// CommitMessage writes a Conventional Commits message for a diff of staged
+// changes.
+func (a *ReActAgent) CommitMessage(diff string) (string, error) {
+	message, err := a.complete([]openaai.ChatCompletionMessage{
+		{Role: openaai.ChatMessageRoleSystem, Content: commitPrompt},
+		{Role: openaai.ChatMessageRoleUser, Content: "Write the commit message for this diff:\n\n" + capDiff(diff)},
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return cleanCommitMessage(message), nil
+}
+
+// Review reviews a diff and returns the issues found, most severe first.
+func (a *ReActAgent) Review(diff string) (*Review, error) {
+	content, err := a.complete([]openaai.ChatCompletionMessage{
+		{Role: openaai.ChatMessageRoleSystem, Content: reviewPrompt},
+		{Role: openaai.ChatMessageRoleUser, Content: "Review this diff:\n\n" + capDiff(numberDiff(diff))},
+	}, &openaai.ChatCompletionResponseFormat{Type: openaai.ChatCompletionResponseFormatTypeJSONObject})
+	if err != nil {
+		return nil, err
+	}
+	var review Review
+	if err := json.Unmarshal([]byte(stripCodeFence(content)), &review); err != nil {
+		return nil, fmt.Errorf("the model returned an invalid review: %w", err)
+	}
+	for i := range review.Issues {
+		issue := &review.Issues[i]
+		issue.Severity = strings.ToLower(issue.Severity)
+		if _, ok := severityOrder[issue.Severity]; !ok {
+			issue.Severity = "medium"
+		}
+	}
+	sort.SliceStable(review.Issues, func(i, j int) bool {
+		return severityOrder[review.Issues[i].Severity] < severityOrder[review.Issues[j].Severity]
+	})
+	return &review, nil
+}
+
+// ApplyFix applies the fix suggested for a review issue with the agent's
+// edit tools. The suggested patch is applied directly when it matches the
+// file; otherwise the agent is asked to make the change.
+func (a *ReActAgent) ApplyFix(issue ReviewIssue) (string, error) {
+	if issue.Patch != "" {
+		result, err := a.applyPatch(issue.Patch)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Fprintf(a.logWriter, "Suggested patch did not apply, asking the agent: %v\n", err)
+	}
+	prompt := fmt.Sprintf("Fix this problem found in code review of %s:%d, using your file tools. Change only what the fix needs.\n\n%s\n%s",
+		issue.File, issue.Line, issue.Title, issue.Explanation)
+	if issue.Patch != "" {
+		prompt += "\n\nThe reviewer suggested this patch, which no longer applies cleanly:\n" + issue.Patch
+	}
+	return a.ProcessCommand(prompt)
+}
+
+// applyPatch applies patch with the apply_patch tool. The files it touches
+// are read with file_reader first, as the edit tools only change files the
+// agent has read.
+func (a *ReActAgent) applyPatch(patch string) (string, error) {
+	patcher, ok := a.tools["apply_patch"]
+	if !ok {
+		return "", fmt.Errorf("the apply_patch tool is not available")
+	}
+	reader, ok := a.tools["file_reader"]
+	if !ok {
+		return "", fmt.Errorf("the file_reader tool is not available")
+	}
+	paths, err := tool.PatchedFiles(patch)
+	if err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		args, _ := json.Marshal(map[string]string{"path": path})
+		if _, err := a.hooks.Execute(reader, "file_reader", args); err != nil {
+			return "", err
+		}
+	}
+	args, _ := json.Marshal(map[string]string{"patch": patch})
+	return a.hooks.Execute(patcher, "apply_patch", args)
+}
+
+// complete sends a single chat completion request without tools and returns
+// the reply.
+func (a *ReActAgent) complete(messages []openaai.ChatCompletionMessage, format *openaai.ChatCompletionResponseFormat) (string, error) {
+	for i := range messages {
+		messages[i].Content = a.redactor.Redact(messages[i].Content)
+	}
+	req := openaai.ChatCompletionRequest{
+		Model:          a.model,
+		Messages:       messages,
+		ResponseFormat: format,
+	}
+	fmt.Fprintln(a.logWriter, "--- Sending request to OpenAI ---")
+	resp, err := a.client.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion error: %w", err)
+	}
+	a.usage.PromptTokens += resp.Usage.PromptTokens
+	a.usage.CompletionTokens += resp.Usage.CompletionTokens
+	a.usage.TotalTokens += resp.Usage.TotalTokens
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("the model returned no answer")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// numberDiff prefixes every line in the hunks of a unified diff with its
+// line number in the new file, so that the model can refer to lines
+// exactly. Removed lines get no number.
+func numberDiff(diff string) string {
+	var b strings.Builder
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			// @@ -a,b +c,d @@
+			inHunk = false
+			if i := strings.Index(text, " +"); i >= 0 {
+				start := strings.TrimPrefix(text[i+1:], "+")
+				if end := strings.IndexAny(start, ", "); end >= 0 {
+					start = start[:end]
+				}
+				if n, err := strconv.Atoi(start); err == nil {
+					line = n
+					inHunk = true
+				}
+			}
+			b.WriteString(text + "\n")
+		case inHunk && strings.HasPrefix(text, "-"):
+			fmt.Fprintf(&b, "%6s %s\n", "", text)
+		case inHunk && (strings.HasPrefix(text, "+") || strings.HasPrefix(text, " ")):
+			fmt.Fprintf(&b, "%6d %s\n", line, text)
+			line++
+		default:
+			if strings.HasPrefix(text, "diff ") {
+				inHunk = false
+			}
+			b.WriteString(text + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// capDiff shortens a diff that would not fit in the model's context.
+func capDiff(diff string) string {
+	if len(diff) <= maxDiffBytes {
+		return diff
+	}
+	return diff[:maxDiffBytes] + fmt.Sprintf("\n\n[diff truncated; %d more bytes not shown]", len(diff)-maxDiffBytes)
+}
+
+// cleanCommitMessage removes code fences and quotes models sometimes wrap
+// the message in.
+func cleanCommitMessage(message string) string {
+	message = strings.TrimSpace(stripCodeFence(message))
+	if len(message) >= 2 && strings.HasPrefix(message, `"`) && strings.HasSuffix(message, `"`) {
+		message = strings.TrimSpace(message[1 : len(message)-1])
+	}
+	return message
+}
+
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	if i := strings.Index(text, "\n"); i >= 0 {
+		text = text[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
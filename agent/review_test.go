@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sgoal/tide/tool"
+)
+
+func TestApplyFixAppliesSuggestedPatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {\n\tprintln(\"helo\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reads := &tool.ReadTracker{}
+	a := &ReActAgent{
+		tools: map[string]tool.Tool{
+			"file_reader": &tool.FileReaderTool{Tracker: reads},
+			"apply_patch": &tool.ApplyPatchTool{Tracker: reads},
+		},
+		logWriter: io.Discard,
+	}
+
+	// The agent has no chat client, so falling back to ProcessCommand
+	// would fail the test.
+	result, err := a.ApplyFix(ReviewIssue{
+		File:  path,
+		Line:  4,
+		Title: "Typo in greeting",
+		Patch: "--- " + path + "\n+++ " + path + "\n@@ -3,3 +3,3 @@\n func main() {\n-\tprintln(\"helo\")\n+\tprintln(\"hello\")\n }\n",
+	})
+	if err != nil {
+		t.Fatalf("ApplyFix: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `println("hello")`) {
+		t.Errorf("the patch was not applied; result %q, file:\n%s", result, data)
+	}
+}
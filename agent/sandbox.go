@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sgoal/tide/tool"
+)
+
+// sandboxFor returns the sandbox profile the user selected for the named tool,
+// or nil when the tool runs unconfined. A broken configuration falls back to
+// the strictest profile rather than running the tool unconfined.
+func sandboxFor(toolName string, logWriter io.Writer) *tool.SandboxProfile {
+	config, err := tool.LoadSandboxConfig(tool.DefaultSandboxPath())
+	if err == nil {
+		var profile *tool.SandboxProfile
+		if profile, err = config.ProfileFor(toolName); err == nil {
+			return profile
+		}
+	}
+	fmt.Fprintf(logWriter, "Error loading sandbox config, %s will run in the default sandbox: %v\n", toolName, err)
+	return &tool.SandboxProfile{}
+}
@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sgoal/tide/search"
+)
+
+// searchBackend returns the search backend selected in the user's search
+// configuration. A broken configuration falls back to DuckDuckGo.
+func searchBackend(logWriter io.Writer) search.Backend {
+	config, err := search.LoadConfig(search.DefaultConfigPath())
+	if err == nil {
+		var backend search.Backend
+		if backend, err = config.NewBackend(); err == nil {
+			return backend
+		}
+	}
+	fmt.Fprintf(logWriter, "Error loading search config, using DuckDuckGo: %v\n", err)
+	return &search.DuckDuckGo{}
+}
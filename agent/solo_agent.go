@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	openaai "github.com/sashabaranov/go-openai"
+	"github.com/sgoal/tide/redact"
 	"github.com/sgoal/tide/tool"
 )
 
@@ -20,6 +21,8 @@ type SoloAgent struct {
 	history      []openaai.ChatCompletionMessage
 	logWriter    io.Writer
 	systemPrompt string
+	hooks        *Hooks
+	redactor     *redact.Redactor
 }
 
 // NewSoloAgent creates a new SoloAgent with ReAct framework.
@@ -52,6 +55,8 @@ func NewSoloAgent(logWriter io.Writer) (*SoloAgent, error) {
 	if logWriter == nil {
 		logWriter = io.Discard
 	}
+	redactor := newRedactor(logWriter)
+	logWriter = redact.NewWriter(logWriter, redactor)
 
 	systemPrompt := `You are an autonomous software development agent. You can think, plan, execute, and reflect on your actions.
 
@@ -75,14 +80,35 @@ Always follow this pattern:
 
 Be autonomous and complete tasks from start to finish.`
 
+	// Edit tools require files to have been read with file_reader first
+	reads := &tool.ReadTracker{}
+	format := formatConfig(logWriter)
+
 	// Discover all available tools dynamically
 	availableTools := map[string]tool.Tool{
 		"deployer":    &tool.DeployerTool{},
-		"code_writer": &tool.CodeWriterTool{},
-		"file_editor": &tool.FileEditorTool{},
-		"terminal":    &tool.TerminalTool{},
-		"search":      &tool.SearchTool{},
+		"code_writer": &tool.CodeWriterTool{Tracker: reads, Format: format},
+		"file_editor": &tool.FileEditorTool{Tracker: reads, Format: format},
+		"file_reader": &tool.FileReaderTool{Tracker: reads},
+		"apply_patch": &tool.ApplyPatchTool{Tracker: reads, Format: format},
+		"glob":        &tool.GlobTool{},
+		"grep":        &tool.GrepTool{},
+		"terminal": &tool.TerminalTool{
+			Sandbox:    sandboxFor("terminal", logWriter),
+			Persistent: os.Getenv("TIDE_PERSISTENT_SHELL") != "",
+		},
+		"search":      &tool.SearchTool{Backend: searchBackend(logWriter)},
+		"web_fetch":   &tool.WebFetchTool{},
+		"docs_search": &tool.DocsSearchTool{},
+		"view_image":  &tool.ViewImageTool{},
+		"process":     &tool.ProcessManagerTool{Sandbox: sandboxFor("process", logWriter)},
+		"go_test":     &tool.GoTestTool{Sandbox: sandboxFor("go_test", logWriter)},
+		"lint":        &tool.LintTool{Sandbox: sandboxFor("lint", logWriter)},
+		"debugger":    &tool.DebuggerTool{Sandbox: sandboxFor("debugger", logWriter)},
+		"git":         &tool.GitTool{},
+		"lsp":         &tool.LSPTool{Manager: lspManager(logWriter), Tracker: reads},
 	}
+	registerPlugins(availableTools, logWriter)
 
 	// Build dynamic tool descriptions for system prompt
 	toolDescriptions := ""
@@ -93,17 +119,27 @@ Be autonomous and complete tasks from start to finish.`
 	// Update system prompt with dynamic tool list
 	systemPrompt = fmt.Sprintf(systemPrompt, toolDescriptions)
 
+	hooks, err := LoadHooks(DefaultHooksPath())
+	if err != nil {
+		fmt.Fprintf(logWriter, "⚠️ Error loading hooks: %v\n", err)
+	}
+
 	return &SoloAgent{
 		client:       client,
 		tools:        availableTools,
 		maxLoops:     500,
 		logWriter:    logWriter,
 		systemPrompt: systemPrompt,
+		hooks:        hooks,
+		redactor:     redactor,
 	}, nil
 }
 
 // Run runs the solo agent to complete the given task using ReAct framework.
+// Background processes started during the run are stopped when it returns.
 func (a *SoloAgent) Run(task string) error {
+	defer closeTools(a.tools)
+
 	fmt.Fprintf(a.logWriter, "🚀 Solo Agent Starting...\n")
 	fmt.Fprintf(a.logWriter, "📝 Task: %s\n", task)
 	fmt.Fprintf(a.logWriter, "%s\n", strings.Repeat("=", 50))
@@ -116,19 +152,23 @@ func (a *SoloAgent) Run(task string) error {
 		},
 		{
 			Role:    openaai.ChatMessageRoleUser,
-			Content: task,
+			Content: a.redactor.Redact(task),
 		},
 	}
 
 	// Define available tools for the agent dynamically
 	tools := []openaai.Tool{}
 	for name, t := range a.tools {
+		parameters := getToolParameters(name)
+		if provider, ok := t.(tool.SchemaProvider); ok {
+			parameters = provider.Parameters()
+		}
 		tools = append(tools, openaai.Tool{
 			Type: openaai.ToolTypeFunction,
 			Function: &openaai.FunctionDefinition{
 				Name:        name,
 				Description: t.Description(),
-				Parameters:  getToolParameters(name),
+				Parameters:  parameters,
 			},
 		})
 	}
@@ -149,8 +189,11 @@ func (a *SoloAgent) Run(task string) error {
 			return fmt.Errorf("chat completion error: %w", err)
 		}
 
+		// The model has seen the images of the last tool calls now.
+		dropImages(a.history)
+
 		respMsg := resp.Choices[0].Message
-		a.history = append(a.history, respMsg)
+		a.history = append(a.history, redactMessage(a.redactor, respMsg))
 
 		// Display the agent's thought/plan
 		if respMsg.Content != "" {
@@ -171,13 +214,14 @@ func (a *SoloAgent) Run(task string) error {
 			fmt.Fprintf(a.logWriter, "📄 Arguments: %s\n", toolCall.Function.Arguments)
 
 			if tool, exists := a.tools[toolCall.Function.Name]; exists {
-				observation, err := tool.Execute(json.RawMessage(toolCall.Function.Arguments))
+				observation, err := a.hooks.Execute(tool, toolCall.Function.Name, json.RawMessage(toolCall.Function.Arguments))
 				if err != nil {
-					observation = fmt.Sprintf("❌ Error: %v", err)
+					observation = strings.TrimSpace(fmt.Sprintf("❌ Error: %v\n\n%s", err, observation))
 				}
 				if observation == "" {
 					observation = "✅ Operation completed successfully"
 				}
+				observation = a.redactor.Redact(observation)
 
 				fmt.Fprintf(a.logWriter, "👀 Observation: %s\n", observation)
 
@@ -192,6 +236,9 @@ func (a *SoloAgent) Run(task string) error {
 				fmt.Fprintf(a.logWriter, "❌ Tool '%s' not found\n", toolCall.Function.Name)
 			}
 		}
+		if msg, ok := toolImagesMessage(a.tools); ok {
+			a.history = append(a.history, msg)
+		}
 
 		fmt.Fprintf(a.logWriter, "%s\n", strings.Repeat("=", 50))
 	}
@@ -202,16 +249,6 @@ func (a *SoloAgent) Run(task string) error {
 // getToolParameters returns the JSON schema for tool parameters based on tool name
 func getToolParameters(toolName string) json.RawMessage {
 	parameters := map[string]json.RawMessage{
-		"search": json.RawMessage(`{
-			"type": "object",
-			"properties": {
-				"query": {
-					"type": "string",
-					"description": "The search query."
-				}
-			},
-			"required": ["query"]
-		}`),
 		"code_writer": json.RawMessage(`{
 			"type": "object",
 			"properties": {
@@ -236,6 +273,19 @@ func getToolParameters(toolName string) json.RawMessage {
 				"command": {
 					"type": "string",
 					"description": "The command to execute."
+				},
+				"cwd": {
+					"type": "string",
+					"description": "The working directory to run the command in. Defaults to the current directory."
+				},
+				"env": {
+					"type": "object",
+					"additionalProperties": {"type": "string"},
+					"description": "Extra environment variables for the command."
+				},
+				"timeout_seconds": {
+					"type": "integer",
+					"description": "Kill the command after this many seconds. Defaults to 120."
 				}
 			},
 			"required": ["command"]
@@ -253,14 +303,31 @@ func getToolParameters(toolName string) json.RawMessage {
 				},
 				"search_text": {
 					"type": "string",
-					"description": "The text to search for in the file content."
+					"description": "The exact text to search for in the file content. It must match exactly once unless replace_all is set."
 				},
 				"replace_text": {
 					"type": "string",
 					"description": "The text to replace the searched content with."
+				},
+				"replace_all": {
+					"type": "boolean",
+					"description": "Replace every occurrence of search_text instead of requiring a unique match."
+				},
+				"edits": {
+					"type": "array",
+					"description": "Several edits to apply in order, instead of search_text/replace_text. Either all of them are applied or none.",
+					"items": {
+						"type": "object",
+						"properties": {
+							"search_text": {"type": "string"},
+							"replace_text": {"type": "string"},
+							"replace_all": {"type": "boolean"}
+						},
+						"required": ["search_text", "replace_text"]
+					}
 				}
 			},
-			"required": ["dir_path", "file_name", "search_text", "replace_text"]
+			"required": ["dir_path", "file_name"]
 		}`),
 		"deployer": json.RawMessage(`{
 			"type": "object",
@@ -0,0 +1,31 @@
+package agent
+
+import openaai "github.com/sashabaranov/go-openai"
+
+// modelPrice is the price in US dollars per million tokens.
+type modelPrice struct {
+	prompt     float64
+	completion float64
+}
+
+var modelPrices = map[string]modelPrice{
+	openaai.GPT4o:         {prompt: 2.50, completion: 10.00},
+	openaai.GPT4o20240806: {prompt: 2.50, completion: 10.00},
+	openaai.GPT4o20241120: {prompt: 2.50, completion: 10.00},
+	openaai.GPT4oMini:     {prompt: 0.15, completion: 0.60},
+	openaai.GPT4Turbo:     {prompt: 10.00, completion: 30.00},
+	openaai.GPT3Dot5Turbo: {prompt: 0.50, completion: 1.50},
+	openaai.GPT4Dot1:      {prompt: 2.00, completion: 8.00},
+	openaai.GPT4Dot1Mini:  {prompt: 0.40, completion: 1.60},
+}
+
+// EstimateCost returns the approximate cost in US dollars of usage on model.
+// It reports false when the model's price is unknown.
+func EstimateCost(model string, usage openaai.Usage) (float64, bool) {
+	price, ok := modelPrices[model]
+	if !ok {
+		return 0, false
+	}
+	cost := float64(usage.PromptTokens)*price.prompt + float64(usage.CompletionTokens)*price.completion
+	return cost / 1_000_000, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sgoal/tide/agent"
+)
+
+// stdin is shared by every prompt so that buffered input is not lost.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask prints question and returns the user's answer in lower case. End of
+// input yields an empty answer.
+func ask(question string) string {
+	fmt.Print(question)
+	answer, _ := stdin.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(answer))
+}
+
+// gitOutput runs git with args and returns its standard output.
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// newAgent creates the agent for a command. Its log goes to stderr when
+// verbose is set.
+func newAgent(verbose bool) (*agent.ReActAgent, error) {
+	var logWriter io.Writer = io.Discard
+	if verbose {
+		logWriter = os.Stderr
+	}
+	return agent.NewReActAgent(logWriter)
+}
+
+// printUsage reports the tokens the agent used, with the estimated cost when
+// the model's price is known.
+func printUsage(a *agent.ReActAgent) {
+	usage := a.Usage()
+	if usage.TotalTokens == 0 {
+		return
+	}
+	if cost, ok := agent.EstimateCost(a.Model(), usage); ok {
+		fmt.Fprintf(os.Stderr, "(%d tokens, about $%.4f)\n", usage.TotalTokens, cost)
+	} else {
+		fmt.Fprintf(os.Stderr, "(%d tokens)\n", usage.TotalTokens)
+	}
+}
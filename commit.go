@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runCommit implements "tide commit": it has the agent write a Conventional
+// Commits message for the staged changes and commits them once the user
+// accepts it.
+func runCommit(args []string) error {
+	flags := flag.NewFlagSet("commit", flag.ContinueOnError)
+	all := flags.Bool("a", false, "stage modified and deleted files first, like git commit -a")
+	yes := flags.Bool("y", false, "commit without asking")
+	dryRun := flags.Bool("n", false, "only print the message")
+	verbose := flags.Bool("v", false, "log the agent's requests to stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tide commit [-a] [-y] [-n] [-v]\n\nWrites a commit message for the staged changes and commits them.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *all {
+		if _, err := gitOutput("add", "--update"); err != nil {
+			return err
+		}
+	}
+	diff, err := gitOutput("diff", "--cached", "--no-color", "-M")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		return fmt.Errorf("nothing is staged; stage changes with git add or use -a")
+	}
+
+	a, err := newAgent(*verbose)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	defer printUsage(a)
+
+	message, err := a.CommitMessage(diff)
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return fmt.Errorf("the model returned an empty commit message")
+	}
+	fmt.Printf("\n%s\n\n", message)
+	if *dryRun {
+		return nil
+	}
+
+	edit := false
+	if !*yes {
+		switch ask("Commit with this message? [y]es, [e]dit, [n]o: ") {
+		case "y", "yes":
+		case "e", "edit":
+			edit = true
+		default:
+			fmt.Println("Not committed.")
+			return nil
+		}
+	}
+	return gitCommit(message, edit)
+}
+
+// gitCommit commits the staged changes with message, opening git's editor
+// on it first when edit is set.
+func gitCommit(message string, edit bool) error {
+	file, err := os.CreateTemp("", "tide-commit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(message + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	args := []string{"commit", "--file", file.Name()}
+	if edit {
+		args = append(args, "--edit")
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	return nil
+}
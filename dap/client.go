@@ -0,0 +1,630 @@
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// startTimeout bounds how long Delve may take to start listening.
+	startTimeout = 30 * time.Second
+	// shutdownTimeout bounds the polite disconnect before Delve is killed.
+	shutdownTimeout = 3 * time.Second
+	// maxOutputBytes is how much unread program output is kept.
+	maxOutputBytes = 64 * 1024
+	// maxStderrBytes is how much Delve output is kept for error messages.
+	maxStderrBytes = 4096
+)
+
+// LaunchConfig describes the program to debug.
+type LaunchConfig struct {
+	// Mode is "debug" to debug a main package or "test" to debug the tests of
+	// a package.
+	Mode string
+	// Program is the directory of the package.
+	Program    string
+	Args       []string
+	BuildFlags string
+	// Dir is the working directory of the program.
+	Dir string
+	// Output is the path of the binary Delve builds.
+	Output string
+}
+
+// State is the execution state of the debuggee.
+type State struct {
+	Running bool
+	// Stopped is set while the debuggee is stopped.
+	Stopped    *StoppedEvent
+	Exited     bool
+	ExitCode   int
+	Terminated bool
+}
+
+// Client is a debug session with a Delve DAP server ("dlv dap").
+type Client struct {
+	cmd    *exec.Cmd
+	conn   net.Conn
+	delve  *tailBuffer
+	exited chan struct{}
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	seq     int
+	pending map[int]chan *message
+	// closed is closed when the connection fails or Delve exits.
+	closed chan struct{}
+	err    error
+
+	initialized chan struct{}
+	initOnce    sync.Once
+
+	state State
+	// changed is closed and replaced whenever state changes.
+	changed chan struct{}
+	output  []byte
+}
+
+// Start starts cmd, which must run "dlv dap" listening on a local address,
+// and connects to it. Delve's output is consumed by the client.
+func Start(ctx context.Context, cmd *exec.Cmd) (*Client, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		cmd:         cmd,
+		delve:       &tailBuffer{max: maxStderrBytes},
+		exited:      make(chan struct{}),
+		pending:     make(map[int]chan *message),
+		closed:      make(chan struct{}),
+		initialized: make(chan struct{}),
+		changed:     make(chan struct{}),
+	}
+	cmd.Stderr = c.delve
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start delve: %w", err)
+	}
+
+	// Delve announces its address on stdout; the rest of its output is only
+	// kept for error messages.
+	addrs := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if _, addr, ok := strings.Cut(line, "listening at:"); ok {
+				select {
+				case addrs <- strings.TrimSpace(addr):
+				default:
+				}
+				continue
+			}
+			c.delve.Write([]byte(line + "\n"))
+		}
+		io.Copy(io.Discard, stdout)
+		cmd.Wait()
+		close(c.exited)
+	}()
+
+	timer := time.NewTimer(startTimeout)
+	defer timer.Stop()
+	var addr string
+	select {
+	case addr = <-addrs:
+	case <-c.exited:
+		return nil, fmt.Errorf("delve exited before it was ready%s", c.delve.suffix())
+	case <-timer.C:
+		c.kill()
+		return nil, fmt.Errorf("delve did not start within %s%s", startTimeout, c.delve.suffix())
+	case <-ctx.Done():
+		c.kill()
+		return nil, ctx.Err()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		c.kill()
+		return nil, fmt.Errorf("failed to connect to delve at %s: %w", addr, err)
+	}
+	c.conn = conn
+	go c.readLoop(bufio.NewReader(conn))
+	return c, nil
+}
+
+// Launch initializes the session and builds and starts the program. The
+// program does not run until ConfigurationDone is called, so breakpoints can
+// be set first.
+func (c *Client) Launch(ctx context.Context, config LaunchConfig) error {
+	err := c.call(ctx, "initialize", map[string]any{
+		"clientID":        "tide",
+		"clientName":      "Tide",
+		"adapterID":       "go",
+		"linesStartAt1":   true,
+		"columnsStartAt1": true,
+		"pathFormat":      "path",
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	args := map[string]any{
+		"request":              "launch",
+		"mode":                 config.Mode,
+		"program":              config.Program,
+		"stopOnEntry":          false,
+		"hideSystemGoroutines": true,
+	}
+	if len(config.Args) > 0 {
+		args["args"] = config.Args
+	}
+	if config.BuildFlags != "" {
+		args["buildFlags"] = config.BuildFlags
+	}
+	if config.Dir != "" {
+		args["cwd"] = config.Dir
+	}
+	if config.Output != "" {
+		args["output"] = config.Output
+	}
+	if err := c.call(ctx, "launch", args, nil); err != nil {
+		return err
+	}
+
+	select {
+	case <-c.initialized:
+		return nil
+	case <-c.closed:
+		return c.connErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetBreakpoints replaces the breakpoints in the file at path.
+func (c *Client) SetBreakpoints(ctx context.Context, path string, breakpoints []SourceBreakpoint) ([]Breakpoint, error) {
+	var body struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	err := c.call(ctx, "setBreakpoints", map[string]any{
+		"source":      Source{Path: path},
+		"breakpoints": nonNil(breakpoints),
+	}, &body)
+	return body.Breakpoints, err
+}
+
+// SetFunctionBreakpoints replaces the breakpoints on functions.
+func (c *Client) SetFunctionBreakpoints(ctx context.Context, breakpoints []FunctionBreakpoint) ([]Breakpoint, error) {
+	var body struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	err := c.call(ctx, "setFunctionBreakpoints", map[string]any{
+		"breakpoints": nonNil(breakpoints),
+	}, &body)
+	return body.Breakpoints, err
+}
+
+// ConfigurationDone starts the launched program.
+func (c *Client) ConfigurationDone(ctx context.Context) error {
+	return c.resume(ctx, "configurationDone", map[string]any{})
+}
+
+// Continue resumes the program.
+func (c *Client) Continue(ctx context.Context, threadID int) error {
+	return c.resume(ctx, "continue", map[string]any{"threadId": threadID})
+}
+
+// Next steps over the current line of the thread.
+func (c *Client) Next(ctx context.Context, threadID int) error {
+	return c.resume(ctx, "next", map[string]any{"threadId": threadID})
+}
+
+// StepIn steps into the function called on the current line of the thread.
+func (c *Client) StepIn(ctx context.Context, threadID int) error {
+	return c.resume(ctx, "stepIn", map[string]any{"threadId": threadID})
+}
+
+// StepOut runs until the current function of the thread returns.
+func (c *Client) StepOut(ctx context.Context, threadID int) error {
+	return c.resume(ctx, "stepOut", map[string]any{"threadId": threadID})
+}
+
+// Pause stops the running program.
+func (c *Client) Pause(ctx context.Context, threadID int) error {
+	return c.call(ctx, "pause", map[string]any{"threadId": threadID}, nil)
+}
+
+// resume sends a request that lets the program run. The state is marked as
+// running before the request is sent so that a stop reported right after
+// it is not lost.
+func (c *Client) resume(ctx context.Context, command string, args any) error {
+	c.setState(func(s *State) {
+		s.Running = true
+		s.Stopped = nil
+	})
+	err := c.call(ctx, command, args, nil)
+	if err != nil {
+		c.setState(func(s *State) {
+			s.Running = false
+		})
+	}
+	return err
+}
+
+// Wait waits until the program stops or exits, or ctx is done, and returns
+// the state at that point.
+func (c *Client) Wait(ctx context.Context) State {
+	for {
+		c.mu.Lock()
+		state, changed := c.state, c.changed
+		c.mu.Unlock()
+		if !state.Running {
+			return state
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return state
+		}
+	}
+}
+
+// State returns the current execution state.
+func (c *Client) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Threads returns the goroutines of the stopped program.
+func (c *Client) Threads(ctx context.Context) ([]Thread, error) {
+	var body struct {
+		Threads []Thread `json:"threads"`
+	}
+	err := c.call(ctx, "threads", nil, &body)
+	return body.Threads, err
+}
+
+// StackTrace returns up to levels frames of the thread, innermost first.
+func (c *Client) StackTrace(ctx context.Context, threadID, levels int) ([]StackFrame, error) {
+	var body struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	err := c.call(ctx, "stackTrace", map[string]any{
+		"threadId":   threadID,
+		"startFrame": 0,
+		"levels":     levels,
+	}, &body)
+	return body.StackFrames, err
+}
+
+// Scopes returns the variable scopes of a stack frame.
+func (c *Client) Scopes(ctx context.Context, frameID int) ([]Scope, error) {
+	var body struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	err := c.call(ctx, "scopes", map[string]any{"frameId": frameID}, &body)
+	return body.Scopes, err
+}
+
+// Variables returns the variables of a scope or the children of a variable.
+func (c *Client) Variables(ctx context.Context, reference int) ([]Variable, error) {
+	var body struct {
+		Variables []Variable `json:"variables"`
+	}
+	err := c.call(ctx, "variables", map[string]any{"variablesReference": reference}, &body)
+	return body.Variables, err
+}
+
+// Evaluate evaluates a Go expression in a stack frame.
+func (c *Client) Evaluate(ctx context.Context, expression string, frameID int) (Variable, error) {
+	var body struct {
+		Result             string `json:"result"`
+		Type               string `json:"type"`
+		VariablesReference int    `json:"variablesReference"`
+	}
+	args := map[string]any{"expression": expression, "context": "repl"}
+	if frameID != 0 {
+		args["frameId"] = frameID
+	}
+	err := c.call(ctx, "evaluate", args, &body)
+	return Variable{Name: expression, Value: body.Result, Type: body.Type, VariablesReference: body.VariablesReference}, err
+}
+
+// ExceptionInfo describes the panic that stopped the thread.
+func (c *Client) ExceptionInfo(ctx context.Context, threadID int) (*ExceptionInfo, error) {
+	var info ExceptionInfo
+	if err := c.call(ctx, "exceptionInfo", map[string]any{"threadId": threadID}, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// TakeOutput returns the program output received since the last call.
+func (c *Client) TakeOutput() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	output := string(c.output)
+	c.output = nil
+	return output
+}
+
+// Close ends the session, terminating the program and Delve.
+func (c *Client) Close() error {
+	select {
+	case <-c.exited:
+		return nil
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	c.call(ctx, "disconnect", map[string]any{"terminateDebuggee": true}, nil)
+	c.conn.Close()
+	select {
+	case <-c.exited:
+	case <-ctx.Done():
+		c.kill()
+	}
+	return nil
+}
+
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// call sends a request and decodes the body of its response into body, which
+// may be nil.
+func (c *Client) call(ctx context.Context, command string, args, body any) error {
+	c.mu.Lock()
+	c.seq++
+	seq := c.seq
+	reply := make(chan *message, 1)
+	c.pending[seq] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, seq)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(&request{Seq: seq, Type: "request", Command: command, Arguments: args}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-reply:
+		if !msg.Success {
+			return responseError(command, msg)
+		}
+		if body == nil || len(msg.Body) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Body, body)
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return c.connErr()
+	}
+}
+
+func (c *Client) send(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.conn, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	var err error
+	defer func() {
+		c.mu.Lock()
+		c.err = fmt.Errorf("delve connection closed: %w%s", err, c.delve.suffix())
+		c.mu.Unlock()
+		c.setState(func(s *State) {
+			s.Running = false
+			s.Stopped = nil
+			s.Terminated = true
+		})
+		close(c.closed)
+	}()
+
+	for {
+		var data []byte
+		if data, err = readFrame(r); err != nil {
+			return
+		}
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch msg.Type {
+		case "response":
+			c.mu.Lock()
+			reply, ok := c.pending[msg.RequestSeq]
+			c.mu.Unlock()
+			if ok {
+				reply <- &msg
+			}
+		case "event":
+			c.handleEvent(&msg)
+		case "request":
+			// Reverse requests such as runInTerminal are not supported.
+			c.mu.Lock()
+			c.seq++
+			seq := c.seq
+			c.mu.Unlock()
+			c.send(&response{Seq: seq, Type: "response", RequestSeq: msg.Seq, Command: msg.Command, Message: "not supported"})
+		}
+	}
+}
+
+func (c *Client) handleEvent(msg *message) {
+	switch msg.Event {
+	case "initialized":
+		c.initOnce.Do(func() { close(c.initialized) })
+	case "stopped":
+		var event StoppedEvent
+		if json.Unmarshal(msg.Body, &event) != nil {
+			return
+		}
+		c.setState(func(s *State) {
+			s.Running = false
+			s.Stopped = &event
+		})
+	case "continued":
+		c.setState(func(s *State) {
+			s.Running = true
+			s.Stopped = nil
+		})
+	case "exited":
+		var event struct {
+			ExitCode int `json:"exitCode"`
+		}
+		json.Unmarshal(msg.Body, &event)
+		c.setState(func(s *State) {
+			s.Exited = true
+			s.ExitCode = event.ExitCode
+		})
+	case "terminated":
+		c.setState(func(s *State) {
+			s.Running = false
+			s.Stopped = nil
+			s.Terminated = true
+		})
+	case "output":
+		var event struct {
+			Category string `json:"category"`
+			Output   string `json:"output"`
+		}
+		if json.Unmarshal(msg.Body, &event) != nil {
+			return
+		}
+		// Console output is Delve talking about itself.
+		if event.Category != "stdout" && event.Category != "stderr" {
+			return
+		}
+		c.mu.Lock()
+		c.output = append(c.output, event.Output...)
+		if over := len(c.output) - maxOutputBytes; over > 0 {
+			c.output = c.output[over:]
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Client) setState(update func(*State)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.state)
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *Client) connErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// responseError builds an error from a failed response. Delve puts the
+// details, such as build errors, in the body rather than the message.
+func responseError(command string, msg *message) error {
+	var body struct {
+		Error struct {
+			Format string `json:"format"`
+		} `json:"error"`
+	}
+	json.Unmarshal(msg.Body, &body)
+	text := body.Error.Format
+	if text == "" {
+		text = msg.Message
+	}
+	if text == "" {
+		text = command + " failed"
+	}
+	return errors.New(text)
+}
+
+// readFrame reads one message body framed by a Content-Length header.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// nonNil returns an empty slice for nil so that it is sent as [] rather than
+// null, which clears every breakpoint.
+func nonNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu   sync.Mutex
+	max  int
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - b.max; over > 0 {
+		b.data = b.data[over:]
+	}
+	return len(p), nil
+}
+
+// suffix formats the kept output for appending to an error message.
+func (b *tailBuffer) suffix() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	text := strings.TrimSpace(string(b.data))
+	if text == "" {
+		return ""
+	}
+	return "\n" + text
+}
@@ -0,0 +1,213 @@
+package docindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// maxSignatureLines caps the declarations of large types and const blocks.
+const maxSignatureLines = 20
+
+// goPackage is a package printed by go list -json.
+type goPackage struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	GoFiles    []string
+	CgoFiles   []string
+	Standard   bool
+	Error      *struct{ Err string }
+}
+
+// addGoPackages indexes the documentation of the packages the workspace at
+// root imports, directly or indirectly, and of its own packages. The
+// standard library is included; internal packages are not. A workspace
+// without a go.mod or go.work file has no packages to index.
+func (ix *Index) addGoPackages(ctx context.Context, root string) error {
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		if _, err := os.Stat(filepath.Join(root, "go.work")); err != nil {
+			return nil
+		}
+	}
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-deps", "-json=ImportPath,Name,Dir,GoFiles,CgoFiles,Standard,Error", "./...")
+	cmd.Dir = root
+	// Only read what is already in the module cache, and leave go.mod and
+	// go.sum alone.
+	cmd.Env = append(os.Environ(), "GOPROXY=off", "GOFLAGS="+readOnlyGoFlags(ctx, root))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && len(out) == 0 {
+		return fmt.Errorf("go list failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg goPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("invalid go list output: %w", err)
+		}
+		if pkg.Name == "main" || isInternal(pkg.ImportPath) {
+			continue
+		}
+		if pkg.Dir == "" || len(pkg.GoFiles)+len(pkg.CgoFiles) == 0 {
+			if pkg.Error != nil {
+				ix.Errors = append(ix.Errors, fmt.Sprintf("%s: %s", pkg.ImportPath, pkg.Error.Err))
+			}
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := ix.addGoPackage(pkg); err != nil {
+			ix.Errors = append(ix.Errors, fmt.Sprintf("%s: %v", pkg.ImportPath, err))
+			continue
+		}
+		ix.Packages++
+	}
+	return nil
+}
+
+// readOnlyGoFlags returns the user's GOFLAGS, such as build tags, with any
+// -mod flag replaced by -mod=readonly. -mod=vendor is kept, since it does not
+// write go.mod or go.sum either.
+func readOnlyGoFlags(ctx context.Context, root string) string {
+	cmd := exec.CommandContext(ctx, "go", "env", "GOFLAGS")
+	cmd.Dir = root
+	out, _ := cmd.Output()
+	var flags []string
+	mod := "-mod=readonly"
+	for _, flag := range strings.Fields(string(out)) {
+		if strings.HasPrefix(flag, "-mod=") || strings.HasPrefix(flag, "--mod=") {
+			if strings.HasSuffix(flag, "=vendor") {
+				mod = flag
+			}
+			continue
+		}
+		flags = append(flags, flag)
+	}
+	return strings.Join(append(flags, mod), " ")
+}
+
+func isInternal(importPath string) bool {
+	for _, part := range strings.Split(importPath, "/") {
+		if part == "internal" || part == "vendor" {
+			return true
+		}
+	}
+	return false
+}
+
+// addGoPackage indexes the package doc and exported symbols of a package.
+func (ix *Index) addGoPackage(pkg goPackage) error {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range append(pkg.GoFiles, pkg.CgoFiles...) {
+		file, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	p, err := doc.NewFromFiles(fset, files, pkg.ImportPath)
+	if err != nil {
+		return err
+	}
+
+	add := func(kind Kind, name, signature, text string) {
+		ix.Entries = append(ix.Entries, Entry{
+			Kind:      kind,
+			Name:      name,
+			Location:  pkg.ImportPath,
+			Signature: signature,
+			Text:      strings.TrimSpace(text),
+		})
+	}
+	add(KindPackage, pkg.ImportPath, "package "+p.Name+` // import "`+pkg.ImportPath+`"`, p.Doc)
+
+	// Every const or var is shown with its own spec, so that a symbol of
+	// a long const block does not show the whole block.
+	values := func(kind Kind, values []*doc.Value) {
+		for _, v := range values {
+			for _, spec := range v.Decl.Specs {
+				vs, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				// The comments of the spec go into the text instead.
+				bare := *vs
+				bare.Doc, bare.Comment = nil, nil
+				signature := capLines(declString(fset, &ast.GenDecl{Tok: v.Decl.Tok, Specs: []ast.Spec{&bare}}))
+				text := v.Doc
+				if vs.Doc != nil {
+					text = vs.Doc.Text() + "\n" + text
+				} else if vs.Comment != nil {
+					text = vs.Comment.Text() + "\n" + text
+				}
+				for _, name := range vs.Names {
+					if name.IsExported() {
+						add(kind, p.Name+"."+name.Name, signature, text)
+					}
+				}
+			}
+		}
+	}
+	funcs := func(prefix string, funcs []*doc.Func) {
+		for _, f := range funcs {
+			kind := KindFunc
+			if f.Recv != "" {
+				kind = KindMethod
+			}
+			add(kind, prefix+f.Name, capLines(declString(fset, &ast.FuncDecl{Recv: f.Decl.Recv, Name: f.Decl.Name, Type: f.Decl.Type})), f.Doc)
+		}
+	}
+
+	values(KindConst, p.Consts)
+	values(KindVar, p.Vars)
+	funcs(p.Name+".", p.Funcs)
+	for _, t := range p.Types {
+		add(KindType, p.Name+"."+t.Name, capLines(declString(fset, t.Decl)), t.Doc)
+		values(KindConst, t.Consts)
+		values(KindVar, t.Vars)
+		// Constructors are listed under their type, like go doc does, but
+		// named as package functions.
+		funcs(p.Name+".", t.Funcs)
+		funcs(p.Name+"."+t.Name+".", t.Methods)
+	}
+	return nil
+}
+
+// declString prints a declaration like gofmt.
+func declString(fset *token.FileSet, node any) string {
+	var b bytes.Buffer
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := config.Fprint(&b, fset, node); err != nil {
+		return ""
+	}
+	return b.String()
+}
+
+// capLines cuts a declaration after maxSignatureLines.
+func capLines(decl string) string {
+	lines := strings.Split(decl, "\n")
+	if len(lines) <= maxSignatureLines {
+		return decl
+	}
+	return strings.Join(lines[:maxSignatureLines], "\n") + "\n\t// ..."
+}
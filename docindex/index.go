@@ -0,0 +1,230 @@
+// Package docindex builds an offline search index over the documentation of
+// a Go workspace: the doc comments of the packages it depends on, as go doc
+// shows them, and the markdown files in the workspace.
+package docindex
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kind is the kind of an indexed entry.
+type Kind string
+
+const (
+	KindPackage Kind = "package"
+	KindFunc    Kind = "func"
+	KindMethod  Kind = "method"
+	KindType    Kind = "type"
+	KindConst   Kind = "const"
+	KindVar     Kind = "var"
+	// KindSection is a section of a markdown file.
+	KindSection Kind = "section"
+)
+
+// Entry is a documented symbol or a section of a markdown document.
+type Entry struct {
+	Kind Kind
+	// Name is the qualified name of a symbol, such as "http.Client.Do", or
+	// the heading path of a section.
+	Name string
+	// Location is the import path of a symbol's package, or the path and
+	// line of a section.
+	Location string
+	// Signature is the declaration of a symbol.
+	Signature string
+	// Text is the doc comment of a symbol or the text of a section.
+	Text string
+}
+
+// IsGo reports whether the entry documents a Go symbol or package.
+func (e *Entry) IsGo() bool {
+	return e.Kind != KindSection
+}
+
+// Index is a searchable set of entries.
+type Index struct {
+	Entries []Entry
+	// Packages counts the indexed Go packages.
+	Packages int
+	// Files counts the indexed markdown files.
+	Files int
+	// Errors lists the packages and files that could not be indexed.
+	Errors []string
+
+	// lower holds the lowercased name, location and text of every entry.
+	lower []lowered
+	// df counts the entries containing each term.
+	df map[string]int
+}
+
+type lowered struct {
+	name, location, text string
+}
+
+// Build indexes the Go packages the workspace at root depends on, including
+// its own, and the markdown files below root. It only reads packages already
+// in the module cache and never downloads modules.
+func Build(ctx context.Context, root string) (*Index, error) {
+	index := &Index{}
+	// Markdown is indexed first: it is quick, and still worth searching if
+	// go list fails or uses up the time.
+	if err := index.addMarkdown(ctx, root); err != nil {
+		return nil, err
+	}
+	if err := index.addGoPackages(ctx, root); err != nil {
+		index.Errors = append(index.Errors, err.Error())
+	}
+	index.df = make(map[string]int)
+	index.lower = make([]lowered, len(index.Entries))
+	for i := range index.Entries {
+		e := &index.Entries[i]
+		index.lower[i] = lowered{strings.ToLower(e.Name), strings.ToLower(e.Location), strings.ToLower(e.Text)}
+		seen := make(map[string]bool)
+		for _, term := range terms(e.Name + " " + e.Location + " " + e.Text) {
+			if !seen[term] {
+				seen[term] = true
+				index.df[term]++
+			}
+		}
+	}
+	return index, nil
+}
+
+// Hit is an entry matching a query.
+type Hit struct {
+	*Entry
+	Score float64
+	// Matched counts the query terms the entry contains.
+	Matched int
+}
+
+// Search returns up to limit entries matching query, best first. Entries
+// are ranked by how many of the query terms they contain, then by a score
+// that weighs matches in the name over matches in the location and text and
+// rare terms over common ones. Only entries for which filter returns true
+// are considered; a nil filter allows all.
+func (ix *Index) Search(query string, limit int, filter func(*Entry) bool) []Hit {
+	queryTerms := unique(terms(query))
+	if len(queryTerms) == 0 {
+		return nil
+	}
+	lowerQuery := strings.ToLower(strings.TrimSpace(query))
+
+	var hits []Hit
+	n := float64(len(ix.Entries))
+	for i := range ix.Entries {
+		e := &ix.Entries[i]
+		if filter != nil && !filter(e) {
+			continue
+		}
+		name, location, text := ix.lower[i].name, ix.lower[i].location, ix.lower[i].text
+		hit := Hit{Entry: e}
+		for _, term := range queryTerms {
+			idf := math.Log(1 + n/float64(1+ix.df[term]))
+			score := 0.0
+			if containsWord(name, term) {
+				score += 6
+			} else if strings.Contains(name, term) {
+				score += 3
+			}
+			if strings.Contains(location, term) {
+				score += 1.5
+			}
+			score += math.Min(float64(strings.Count(text, term)), 5) * 0.5
+			if score > 0 {
+				hit.Matched++
+				hit.Score += score * idf
+			}
+		}
+		if hit.Matched == 0 {
+			continue
+		}
+		// A query naming the symbol exactly, such as "http.Client" or
+		// "Client.Do", puts it first.
+		if name == lowerQuery || strings.HasSuffix(name, "."+lowerQuery) {
+			hit.Score += 100
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Matched != hits[j].Matched {
+			return hits[i].Matched > hits[j].Matched
+		}
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Excerpt returns the window of at most lines lines of text that contains
+// the most matches of the query terms.
+func Excerpt(text, query string, lines int) string {
+	all := strings.Split(text, "\n")
+	if len(all) <= lines {
+		return text
+	}
+	queryTerms := unique(terms(query))
+	matches := make([]int, len(all))
+	for i, line := range all {
+		lower := strings.ToLower(line)
+		for _, term := range queryTerms {
+			matches[i] += strings.Count(lower, term)
+		}
+	}
+	best, bestCount, count := 0, -1, 0
+	for i := range all {
+		count += matches[i]
+		if i >= lines {
+			count -= matches[i-lines]
+		}
+		if start := max(i-lines+1, 0); i >= lines-1 && count > bestCount {
+			best, bestCount = start, count
+		}
+	}
+	excerpt := strings.Join(all[best:best+lines], "\n")
+	if best > 0 {
+		excerpt = "...\n" + excerpt
+	}
+	if best+lines < len(all) {
+		excerpt += "\n..."
+	}
+	return excerpt
+}
+
+// terms splits text into lowercase words for indexing and queries. Words
+// are split at punctuation, so "http.Client" yields "http" and "client".
+func terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+func unique(words []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// containsWord reports whether term is one of the dot-separated parts of a
+// qualified name.
+func containsWord(name, term string) bool {
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '/' || r == ' ' }) {
+		if part == term {
+			return true
+		}
+	}
+	return false
+}
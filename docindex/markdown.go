@@ -0,0 +1,126 @@
+package docindex
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// maxMarkdownBytes skips markdown files too large to be documentation.
+	maxMarkdownBytes = 2 * 1024 * 1024
+	// maxMarkdownFiles bounds how many files are indexed.
+	maxMarkdownFiles = 2000
+)
+
+// skippedDirs hold dependencies or generated files rather than the
+// workspace's documentation.
+var skippedDirs = map[string]bool{"node_modules": true, "vendor": true, "testdata": true}
+
+// addMarkdown indexes the sections of the markdown files below root.
+func (ix *Index) addMarkdown(ctx context.Context, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || skippedDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		if ix.Files >= maxMarkdownFiles {
+			return filepath.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxMarkdownBytes {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			ix.Errors = append(ix.Errors, err.Error())
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		ix.Entries = append(ix.Entries, markdownSections(filepath.ToSlash(rel), string(data))...)
+		ix.Files++
+		return nil
+	})
+}
+
+// markdownSections splits a markdown document at its headings. Each section
+// is named by the file and the path of headings leading to it, such as
+// "README.md > Solo Mode > Usage".
+func markdownSections(path, content string) []Entry {
+	type heading struct {
+		level int
+		title string
+	}
+	var sections []Entry
+	var headings []heading
+	var body []string
+	start := 1
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		if text != "" || len(headings) > 0 {
+			name := path
+			for _, h := range headings {
+				name += " > " + h.title
+			}
+			sections = append(sections, Entry{
+				Kind:     KindSection,
+				Name:     name,
+				Location: fmt.Sprintf("%s:%d", path, start),
+				Text:     text,
+			})
+		}
+		body = nil
+	}
+
+	fence := ""
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			body = append(body, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			body = append(body, line)
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		if level == 0 || level > 6 || (len(line) > level && line[level] != ' ') {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+		// A heading ends the sections of its level and below.
+		for len(headings) > 0 && headings[len(headings)-1].level >= level {
+			headings = headings[:len(headings)-1]
+		}
+		headings = append(headings, heading{level, title})
+		start = i + 1
+	}
+	flush()
+	return sections
+}
@@ -0,0 +1,481 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// shutdownTimeout bounds the polite shutdown before the server is killed.
+	shutdownTimeout = 2 * time.Second
+	// maxStderrBytes is how much server stderr is kept for error messages.
+	maxStderrBytes = 4096
+)
+
+// languageIDs maps file extensions to LSP language identifiers.
+var languageIDs = map[string]string{
+	".go":  "go",
+	".ts":  "typescript",
+	".tsx": "typescriptreact",
+	".js":  "javascript",
+	".jsx": "javascriptreact",
+	".mjs": "javascript",
+	".cjs": "javascript",
+	".py":  "python",
+	".pyi": "python",
+}
+
+// Client is a connection to one running language server.
+type Client struct {
+	name   string
+	root   string
+	cmd    *exec.Cmd
+	conn   *conn
+	stderr *tailBuffer
+	exited chan struct{}
+	// onDiagnostics is called whenever the server publishes diagnostics.
+	onDiagnostics func()
+
+	mu          sync.Mutex
+	documents   map[string]*document
+	diagnostics map[string][]Diagnostic
+	// published records when diagnostics for a URI last arrived.
+	published map[string]time.Time
+}
+
+// document is a file opened in the server.
+type document struct {
+	version int
+	content string
+}
+
+// startClient launches the language server described by config in root and
+// initializes it.
+func startClient(ctx context.Context, name string, config ServerConfig, root string, onDiagnostics func()) (*Client, error) {
+	if len(config.Command) == 0 {
+		return nil, fmt.Errorf("no command configured for language server %s", name)
+	}
+	if _, err := exec.LookPath(config.Command[0]); err != nil {
+		return nil, fmt.Errorf("language server %s is not installed: %w", name, err)
+	}
+
+	cmd := exec.Command(config.Command[0], config.Command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		name:          name,
+		root:          root,
+		cmd:           cmd,
+		stderr:        &tailBuffer{},
+		exited:        make(chan struct{}),
+		onDiagnostics: onDiagnostics,
+		documents:     make(map[string]*document),
+		diagnostics:   make(map[string][]Diagnostic),
+		published:     make(map[string]time.Time),
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start language server %s: %w", name, err)
+	}
+	go func() {
+		cmd.Wait()
+		close(c.exited)
+	}()
+	c.conn = newConn(stdout, stdin, c.handle)
+
+	rootURI := PathToURI(root)
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"rootPath":  root,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": filepath.Base(root)},
+		},
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": true},
+				"hover":              map[string]any{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]any{"linkSupport": true},
+				"references":         map[string]any{},
+				"rename":             map[string]any{},
+				"documentSymbol":     map[string]any{},
+				"publishDiagnostics": map[string]any{},
+			},
+			"workspace": map[string]any{
+				"workspaceFolders": true,
+				"configuration":    true,
+				"symbol":           map[string]any{},
+				"workspaceEdit":    map[string]any{"documentChanges": true},
+			},
+		},
+	}
+	if len(config.InitializationOptions) > 0 {
+		params["initializationOptions"] = config.InitializationOptions
+	}
+	if err := c.conn.Call(ctx, "initialize", params, nil); err != nil {
+		c.kill()
+		return nil, fmt.Errorf("failed to initialize language server %s: %w%s", name, err, c.stderr.suffix())
+	}
+	if err := c.conn.Notify("initialized", map[string]any{}); err != nil {
+		c.kill()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Name returns the name of the language server.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// handle answers the requests and notifications the server sends.
+func (c *Client) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p struct {
+			URI         string       `json:"uri"`
+			Diagnostics []Diagnostic `json:"diagnostics"`
+		}
+		if json.Unmarshal(params, &p) != nil {
+			return nil, nil
+		}
+		c.mu.Lock()
+		if len(p.Diagnostics) == 0 {
+			delete(c.diagnostics, p.URI)
+		} else {
+			c.diagnostics[p.URI] = p.Diagnostics
+		}
+		c.published[p.URI] = time.Now()
+		c.mu.Unlock()
+		if c.onDiagnostics != nil {
+			c.onDiagnostics()
+		}
+		return nil, nil
+	case "workspace/configuration":
+		// No settings: every requested section gets the server's defaults.
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &p)
+		return make([]any, len(p.Items)), nil
+	case "workspace/workspaceFolders":
+		return []map[string]string{{"uri": PathToURI(c.root), "name": filepath.Base(c.root)}}, nil
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		return nil, nil
+	case "workspace/applyEdit":
+		return map[string]any{"applied": false, "failureReason": "edits are applied by the client"}, nil
+	case "window/showMessageRequest":
+		return nil, nil
+	}
+	return nil, &ResponseError{Code: errMethodNotFound, Message: "method not supported: " + method}
+}
+
+// Sync makes sure the server sees the current content of the file at path,
+// opening the document or sending its new content as needed. It reports
+// whether the server's view of the file changed.
+func (c *Client) Sync(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	content := string(data)
+	uri := PathToURI(path)
+
+	c.mu.Lock()
+	doc, ok := c.documents[uri]
+	if ok && doc.content == content {
+		c.mu.Unlock()
+		return false, nil
+	}
+	if !ok {
+		doc = &document{}
+		c.documents[uri] = doc
+	}
+	doc.version++
+	doc.content = content
+	version := doc.version
+	c.mu.Unlock()
+
+	if !ok {
+		languageID := languageIDs[strings.ToLower(filepath.Ext(path))]
+		return true, c.conn.Notify("textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": languageID, "version": version, "text": content},
+		})
+	}
+	return true, c.conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": []map[string]any{{"text": content}},
+	})
+}
+
+// Synced returns the content of the file at path as the server last saw it,
+// and false if the file was never opened.
+func (c *Client) Synced(path string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	doc, ok := c.documents[PathToURI(path)]
+	if !ok {
+		return "", false
+	}
+	return doc.content, true
+}
+
+// SyncOpen re-syncs every open document whose file changed on disk, for
+// example after the agent edited it with another tool.
+func (c *Client) SyncOpen() {
+	c.mu.Lock()
+	var uris []string
+	for uri := range c.documents {
+		uris = append(uris, uri)
+	}
+	c.mu.Unlock()
+	for _, uri := range uris {
+		if path, err := URIToPath(uri); err == nil {
+			c.Sync(path)
+		}
+	}
+}
+
+// Definition returns the locations where the symbol at pos in the file at
+// path is defined.
+func (c *Client) Definition(ctx context.Context, path string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.conn.Call(ctx, "textDocument/definition", c.positionParams(path, pos), &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// References returns the locations that refer to the symbol at pos, including
+// its declaration.
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	params := c.positionParams(path, pos)
+	params["context"] = map[string]bool{"includeDeclaration": true}
+	var locations []Location
+	err := c.conn.Call(ctx, "textDocument/references", params, &locations)
+	return locations, err
+}
+
+// Hover returns the documentation and type information for the symbol at pos.
+func (c *Client) Hover(ctx context.Context, path string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.conn.Call(ctx, "textDocument/hover", c.positionParams(path, pos), &result); err != nil {
+		return "", err
+	}
+	if result == nil {
+		return "", nil
+	}
+	return hoverText(result.Contents), nil
+}
+
+// WorkspaceSymbols returns the symbols in the workspace matching query.
+func (c *Client) WorkspaceSymbols(ctx context.Context, query string) ([]SymbolInformation, error) {
+	var symbols []SymbolInformation
+	err := c.conn.Call(ctx, "workspace/symbol", map[string]string{"query": query}, &symbols)
+	return symbols, err
+}
+
+// Rename returns the edits that rename the symbol at pos to newName. The edits
+// are not applied.
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (*WorkspaceEdit, error) {
+	params := c.positionParams(path, pos)
+	params["newName"] = newName
+	var edit *WorkspaceEdit
+	if err := c.conn.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return nil, err
+	}
+	if edit == nil {
+		return nil, fmt.Errorf("the symbol cannot be renamed")
+	}
+	return edit, nil
+}
+
+// FileDiagnostics returns the latest diagnostics for the file at path, waiting
+// up to timeout for the server to publish them if it has not since since.
+func (c *Client) FileDiagnostics(ctx context.Context, path string, since time.Time, timeout time.Duration) []Diagnostic {
+	uri := PathToURI(path)
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		published := c.published[uri]
+		diagnostics := c.diagnostics[uri]
+		c.mu.Unlock()
+		if published.After(since) || time.Now().After(deadline) {
+			return diagnostics
+		}
+		select {
+		case <-ctx.Done():
+			return diagnostics
+		case <-c.exited:
+			return diagnostics
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Diagnostics returns the latest diagnostics the server published, by path.
+func (c *Client) Diagnostics() map[string][]Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make(map[string][]Diagnostic, len(c.diagnostics))
+	for uri, diagnostics := range c.diagnostics {
+		if path, err := URIToPath(uri); err == nil {
+			result[path] = diagnostics
+		}
+	}
+	return result
+}
+
+func (c *Client) positionParams(path string, pos Position) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]string{"uri": PathToURI(path)},
+		"position":     pos,
+	}
+}
+
+// Close shuts the server down, killing it if it does not exit in time.
+func (c *Client) Close() error {
+	select {
+	case <-c.exited:
+		return nil
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if c.conn.Call(ctx, "shutdown", nil, nil) == nil {
+		c.conn.Notify("exit", nil)
+	}
+	select {
+	case <-c.exited:
+		return nil
+	case <-ctx.Done():
+		c.kill()
+		return nil
+	}
+}
+
+func (c *Client) kill() {
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	<-c.exited
+}
+
+// parseLocations decodes a Location, a list of Locations or a list of
+// LocationLinks.
+func parseLocations(raw json.RawMessage) ([]Location, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '{' {
+		var location Location
+		if err := json.Unmarshal(raw, &location); err != nil {
+			return nil, err
+		}
+		return []Location{location}, nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	var locations []Location
+	for _, item := range items {
+		var link locationLink
+		if json.Unmarshal(item, &link) == nil && link.TargetURI != "" {
+			locations = append(locations, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+			continue
+		}
+		var location Location
+		if err := json.Unmarshal(item, &location); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+// hoverText flattens hover contents, which may be a string, a MarkedString, a
+// MarkupContent or a list of MarkedStrings.
+func hoverText(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var content struct {
+		Language string `json:"language"`
+		Value    string `json:"value"`
+	}
+	if json.Unmarshal(raw, &content) == nil && content.Value != "" {
+		if content.Language != "" {
+			return "```" + content.Language + "\n" + content.Value + "\n```"
+		}
+		return content.Value
+	}
+	var items []json.RawMessage
+	if json.Unmarshal(raw, &items) == nil {
+		var parts []string
+		for _, item := range items {
+			if part := hoverText(item); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	return ""
+}
+
+// tailBuffer keeps the last maxStderrBytes written to it.
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - maxStderrBytes; over > 0 {
+		b.data = b.data[over:]
+	}
+	return len(p), nil
+}
+
+// suffix formats the kept output for appending to an error message.
+func (b *tailBuffer) suffix() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	text := strings.TrimSpace(string(b.data))
+	if text == "" {
+		return ""
+	}
+	return "\n" + text
+}
+
+// sortedPaths returns the keys of m in order.
+func sortedPaths(m map[string][]Diagnostic) []string {
+	paths := make([]string, 0, len(m))
+	for path := range m {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,231 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errMethodNotFound is the JSON-RPC error code for unsupported requests.
+const errMethodNotFound = -32601
+
+// ResponseError is an error returned by the language server.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("language server error %d: %s", e.Code, e.Message)
+}
+
+// message is any JSON-RPC 2.0 message: a request, notification or response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// response is a reply to a request from the server. Unlike message, it always
+// carries a result, as JSON-RPC requires.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+// handlerFunc handles a request or notification from the server. The result
+// is ignored for notifications.
+type handlerFunc func(method string, params json.RawMessage) (any, error)
+
+// conn is a JSON-RPC 2.0 connection using the LSP base protocol, which frames
+// every message with a Content-Length header.
+type conn struct {
+	writer  io.Writer
+	writeMu sync.Mutex
+	handler handlerFunc
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	// done is closed when the connection fails or the server exits.
+	done chan struct{}
+	err  error
+}
+
+func newConn(r io.Reader, w io.Writer, handler handlerFunc) *conn {
+	c := &conn{
+		writer:  w,
+		handler: handler,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Call sends a request and decodes its result into result, which may be nil.
+func (c *conn) Call(ctx context.Context, method string, params, result any) error {
+	data, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	reply := make(chan *message, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.FormatInt(id, 10))
+	if err := c.send(&message{JSONRPC: "2.0", ID: &rawID, Method: method, Params: data}); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-reply:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-ctx.Done():
+		c.Notify("$/cancelRequest", map[string]any{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return c.err
+	}
+}
+
+// Notify sends a notification.
+func (c *conn) Notify(method string, params any) error {
+	data, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.send(&message{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+func (c *conn) send(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(data)
+	return err
+}
+
+func (c *conn) readLoop(r *bufio.Reader) {
+	var err error
+	defer func() {
+		c.mu.Lock()
+		c.err = fmt.Errorf("language server connection closed: %w", err)
+		c.mu.Unlock()
+		close(c.done)
+	}()
+
+	for {
+		var data []byte
+		if data, err = readFrame(r); err != nil {
+			return
+		}
+		var msg message
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			// Requests are answered concurrently so that a slow handler does
+			// not block responses to our own calls.
+			go c.reply(&msg)
+		case msg.Method != "":
+			c.handler(msg.Method, msg.Params)
+		case msg.ID != nil:
+			id, convErr := strconv.ParseInt(string(*msg.ID), 10, 64)
+			if convErr != nil {
+				continue
+			}
+			c.mu.Lock()
+			reply, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				reply <- &msg
+			}
+		}
+	}
+}
+
+func (c *conn) reply(msg *message) {
+	result, err := c.handler(msg.Method, msg.Params)
+	resp := &response{JSONRPC: "2.0", ID: *msg.ID, Result: result}
+	if err != nil {
+		var respErr *ResponseError
+		if !errors.As(err, &respErr) {
+			respErr = &ResponseError{Code: -32603, Message: err.Error()}
+		}
+		resp.Result = nil
+		resp.Error = respErr
+	}
+	c.send(resp)
+}
+
+// readFrame reads one message body framed by LSP headers.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	data := make([]byte, length)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func marshalParams(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("lsp: cannot marshal %T: %w", v, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,321 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerConfig describes how to launch a language server.
+type ServerConfig struct {
+	Command []string `json:"command"`
+	// Extensions are the file extensions the server handles, such as ".go".
+	Extensions []string `json:"extensions"`
+	// RootMarkers are files whose presence in the workspace root means the
+	// server is useful even before a file of its language is opened.
+	RootMarkers           []string        `json:"root_markers,omitempty"`
+	InitializationOptions json.RawMessage `json:"initialization_options,omitempty"`
+}
+
+// Config holds the language servers by name.
+type Config struct {
+	Servers map[string]ServerConfig `json:"servers"`
+}
+
+// DefaultServers are the language servers used unless the configuration
+// overrides them.
+var DefaultServers = map[string]ServerConfig{
+	"gopls": {
+		Command:     []string{"gopls"},
+		Extensions:  []string{".go"},
+		RootMarkers: []string{"go.mod", "go.work"},
+	},
+	"typescript": {
+		Command:     []string{"typescript-language-server", "--stdio"},
+		Extensions:  []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"},
+		RootMarkers: []string{"tsconfig.json", "jsconfig.json", "package.json"},
+	},
+	"pyright": {
+		Command:     []string{"pyright-langserver", "--stdio"},
+		Extensions:  []string{".py", ".pyi"},
+		RootMarkers: []string{"pyproject.toml", "setup.py", "requirements.txt", "pyrightconfig.json"},
+	},
+}
+
+// DefaultConfigPath returns the path of the language server configuration
+// file. It can be overridden with the TIDE_LSP_FILE environment variable.
+func DefaultConfigPath() string {
+	if path := os.Getenv("TIDE_LSP_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "lsp.json")
+}
+
+// LoadConfig reads the language server configuration from path. Servers it
+// defines replace the default server of the same name; a server with an
+// empty command is disabled. A missing file yields the default servers.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{Servers: make(map[string]ServerConfig)}
+	for name, server := range DefaultServers {
+		config.Servers[name] = server
+	}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	var user Config
+	if err := json.Unmarshal(data, &user); err != nil {
+		return config, fmt.Errorf("invalid lsp file %s: %w", path, err)
+	}
+	for name, server := range user.Servers {
+		if len(server.Command) == 0 {
+			delete(config.Servers, name)
+			continue
+		}
+		config.Servers[name] = server
+	}
+	return config, nil
+}
+
+// FileDiagnostics are the diagnostics for one file.
+type FileDiagnostics struct {
+	Path        string
+	Diagnostics []Diagnostic
+}
+
+// Manager starts language servers for a workspace on demand, one per
+// configured server, and shuts them down on Close.
+type Manager struct {
+	root   string
+	config *Config
+
+	// startMu serializes starting servers; mu guards the fields below and is
+	// not held while a server starts, so diagnostics stay readable.
+	startMu sync.Mutex
+	mu      sync.Mutex
+	clients map[string]*Client
+	// failed remembers servers that could not start, so that every request
+	// does not try again. They are retried after retryStartAfter, in case
+	// the server has been installed since.
+	failed map[string]startFailure
+
+	handlerMu     sync.Mutex
+	onDiagnostics func()
+}
+
+// retryStartAfter is how long a server that failed to start is left alone.
+const retryStartAfter = 30 * time.Second
+
+// startFailure is the error of a server that could not start.
+type startFailure struct {
+	err error
+	at  time.Time
+}
+
+// NewManager returns a manager for the workspace at root.
+func NewManager(root string, config *Config) *Manager {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	if config == nil {
+		config = &Config{Servers: DefaultServers}
+	}
+	return &Manager{
+		root:    root,
+		config:  config,
+		clients: make(map[string]*Client),
+		failed:  make(map[string]startFailure),
+	}
+}
+
+// Root returns the workspace root.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// OnDiagnostics sets a function called whenever any server publishes
+// diagnostics. It is called from the server's goroutine.
+func (m *Manager) OnDiagnostics(fn func()) {
+	m.handlerMu.Lock()
+	defer m.handlerMu.Unlock()
+	m.onDiagnostics = fn
+}
+
+func (m *Manager) notifyDiagnostics() {
+	m.handlerMu.Lock()
+	fn := m.onDiagnostics
+	m.handlerMu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// ClientFor returns the language server for the file at path, starting it if
+// needed.
+func (m *Manager) ClientFor(ctx context.Context, path string) (*Client, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, name := range m.serverNames() {
+		if containsFold(m.config.Servers[name].Extensions, ext) {
+			return m.client(ctx, name)
+		}
+	}
+	return nil, fmt.Errorf("no language server is configured for %s files", ext)
+}
+
+// WorkspaceClients returns the running language servers, starting those whose
+// root markers are present in the workspace when none is running yet.
+func (m *Manager) WorkspaceClients(ctx context.Context) ([]*Client, error) {
+	if clients := m.running(); len(clients) > 0 {
+		return clients, nil
+	}
+	var errs []error
+	var clients []*Client
+	for _, name := range m.serverNames() {
+		for _, marker := range m.config.Servers[name].RootMarkers {
+			if _, err := os.Stat(filepath.Join(m.root, marker)); err != nil {
+				continue
+			}
+			client, err := m.client(ctx, name)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				clients = append(clients, client)
+			}
+			break
+		}
+	}
+	if len(clients) == 0 {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, fmt.Errorf("no language server applies to %s; pass the path of a source file", m.root)
+	}
+	return clients, nil
+}
+
+func (m *Manager) client(ctx context.Context, name string) (*Client, error) {
+	m.startMu.Lock()
+	defer m.startMu.Unlock()
+
+	m.mu.Lock()
+	client, running := m.clients[name]
+	failure, failed := m.failed[name]
+	m.mu.Unlock()
+	if running {
+		select {
+		case <-client.exited:
+			// The server crashed; start a new one.
+		default:
+			return client, nil
+		}
+	}
+	if failed && time.Since(failure.at) < retryStartAfter {
+		return nil, failure.err
+	}
+
+	client, err := startClient(ctx, name, m.config.Servers[name], m.root, m.notifyDiagnostics)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		delete(m.clients, name)
+		m.failed[name] = startFailure{err: err, at: time.Now()}
+		return nil, err
+	}
+	delete(m.failed, name)
+	m.clients[name] = client
+	return client, nil
+}
+
+func (m *Manager) running() []*Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var clients []*Client
+	for _, name := range m.sortedClientNames() {
+		clients = append(clients, m.clients[name])
+	}
+	return clients
+}
+
+// Diagnostics returns the latest diagnostics from every running server,
+// sorted by path. Files without diagnostics are omitted.
+func (m *Manager) Diagnostics() []FileDiagnostics {
+	merged := make(map[string][]Diagnostic)
+	for _, client := range m.running() {
+		for path, diagnostics := range client.Diagnostics() {
+			merged[path] = append(merged[path], diagnostics...)
+		}
+	}
+	var result []FileDiagnostics
+	for _, path := range sortedPaths(merged) {
+		diagnostics := merged[path]
+		sort.SliceStable(diagnostics, func(i, j int) bool {
+			a, b := diagnostics[i].Range.Start, diagnostics[j].Range.Start
+			return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+		})
+		result = append(result, FileDiagnostics{Path: path, Diagnostics: diagnostics})
+	}
+	return result
+}
+
+// Close shuts down every running server.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	clients := m.clients
+	m.clients = make(map[string]*Client)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, client := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Close()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (m *Manager) serverNames() []string {
+	names := make([]string, 0, len(m.config.Servers))
+	for name := range m.config.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *Manager) sortedClientNames() []string {
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the Language Server Protocol types used by the client. See
+// https://microsoft.github.io/language-server-protocol/specification.
+
+// Position is a zero-based line and UTF-16 code unit offset.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is returned instead of a Location by servers that support
+// links.
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// Severity levels of a Diagnostic.
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+type Diagnostic struct {
+	Range    Range           `json:"range"`
+	Severity int             `json:"severity,omitempty"`
+	Code     json.RawMessage `json:"code,omitempty"`
+	Source   string          `json:"source,omitempty"`
+	Message  string          `json:"message"`
+}
+
+// SeverityName returns the lower-case name of the diagnostic's severity.
+func (d Diagnostic) SeverityName() string {
+	switch d.Severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit describes changes to several files. Servers use either
+// Changes or DocumentChanges.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []json.RawMessage     `json:"documentChanges,omitempty"`
+}
+
+// FileEdits returns the text edits of the workspace edit by file path. File
+// creations, renames and deletions are not supported.
+func (e *WorkspaceEdit) FileEdits() (map[string][]TextEdit, error) {
+	edits := make(map[string][]TextEdit)
+	for uri, changes := range e.Changes {
+		path, err := URIToPath(uri)
+		if err != nil {
+			return nil, err
+		}
+		edits[path] = append(edits[path], changes...)
+	}
+	for _, raw := range e.DocumentChanges {
+		var change struct {
+			Kind         string `json:"kind"`
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			Edits []TextEdit `json:"edits"`
+		}
+		if err := json.Unmarshal(raw, &change); err != nil {
+			return nil, err
+		}
+		if change.Kind != "" {
+			return nil, fmt.Errorf("the edit would %s a file, which is not supported", change.Kind)
+		}
+		path, err := URIToPath(change.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		edits[path] = append(edits[path], change.Edits...)
+	}
+	return edits, nil
+}
+
+type SymbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+var symbolKinds = []string{
+	"", "file", "module", "namespace", "package", "class", "method", "property", "field",
+	"constructor", "enum", "interface", "function", "variable", "constant", "string", "number",
+	"boolean", "array", "object", "key", "null", "enum member", "struct", "event", "operator",
+	"type parameter",
+}
+
+// KindName returns the lower-case name of the symbol's kind.
+func (s SymbolInformation) KindName() string {
+	if s.Kind > 0 && s.Kind < len(symbolKinds) {
+		return symbolKinds[s.Kind]
+	}
+	return "symbol"
+}
+
+// PathToURI converts a file path to a file:// URI.
+func PathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// URIToPath converts a file:// URI to a file path.
+func URIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// PositionAt converts a one-based line and column, counted in characters, to
+// an LSP position in content.
+func PositionAt(content string, line, column int) Position {
+	lines := strings.Split(content, "\n")
+	pos := Position{Line: max(line-1, 0)}
+	if pos.Line >= len(lines) {
+		return pos
+	}
+	text := lines[pos.Line]
+	for i := 0; i < column-1 && text != ""; i++ {
+		r, size := utf8.DecodeRuneInString(text)
+		pos.Character += utf16.RuneLen(r)
+		text = text[size:]
+	}
+	return pos
+}
+
+// LineColumn converts an LSP position in content to a one-based line and
+// column counted in characters.
+func LineColumn(content string, pos Position) (int, int) {
+	lines := strings.Split(content, "\n")
+	if pos.Line >= len(lines) {
+		return pos.Line + 1, pos.Character + 1
+	}
+	text := lines[pos.Line]
+	column, units := 1, 0
+	for units < pos.Character && text != "" {
+		r, size := utf8.DecodeRuneInString(text)
+		units += utf16.RuneLen(r)
+		text = text[size:]
+		column++
+	}
+	return pos.Line + 1, column
+}
+
+// Offset converts an LSP position to a byte offset in content.
+func Offset(content string, pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		offset += i + 1
+	}
+	for units := 0; units < pos.Character && offset < len(content) && content[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(content[offset:])
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
@@ -1,9 +1,35 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
 	"github.com/sgoal/tide/tui"
 )
 
 func main() {
-	tui.NewTUI()
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	var err error
+	switch command {
+	case "commit":
+		err = runCommit(os.Args[2:])
+	case "review":
+		err = runReview(os.Args[2:])
+	default:
+		tui.NewTUI()
+		return
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tide %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
 }
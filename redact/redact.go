@@ -0,0 +1,223 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces every secret found in the redacted content.
+const Placeholder = "***REDACTED***"
+
+// minSecretLength is the shortest known secret value that is redacted, so that
+// values like "true" or "1" in the environment are left alone.
+const minSecretLength = 8
+
+// detector finds secrets with a regular expression. When group is non-zero
+// only that submatch is redacted, keeping e.g. the variable name readable.
+type detector struct {
+	pattern *regexp.Regexp
+	group   int
+}
+
+var builtinDetectors = []detector{
+	// Private key blocks.
+	{pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	// OpenAI and Anthropic API keys.
+	{pattern: regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{20,}`)},
+	// AWS access key IDs.
+	{pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	// GitHub tokens.
+	{pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	// Slack tokens.
+	{pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}`)},
+	// Google API keys.
+	{pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	// JSON web tokens.
+	{pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	// Bearer tokens in authorization headers.
+	{pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9_\-.=]{16,})`), group: 1},
+	// Assignments to secret-looking variables, as in .env files or `env` output.
+	{pattern: regexp.MustCompile(`\b[A-Z0-9_]*(?:API_?KEY|SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIALS?|PRIVATE_KEY)[A-Z0-9_]*=["']?([^\s"']{8,})`), group: 1},
+	// Quoted secret-looking keys in JSON or YAML.
+	{pattern: regexp.MustCompile(`(?i)["']?[a-z0-9_\-]*(?:api_?key|secret|token|password|passwd)["']?\s*:\s*["']([^"'\s]{8,})["']`), group: 1},
+}
+
+// secretEnvPattern matches the names of environment variables whose values
+// are treated as secrets.
+var secretEnvPattern = regexp.MustCompile(`(?i)(API_?KEY|SECRET|TOKEN|PASSWORD|PASSWD|CREDENTIAL|PRIVATE_KEY)`)
+
+// Redactor removes secrets from text before it is sent to a model provider,
+// shown in logs or written to disk.
+type Redactor struct {
+	mu        sync.RWMutex
+	detectors []detector
+	values    []string
+}
+
+// Config is the user's redaction configuration.
+type Config struct {
+	// Patterns are additional regular expressions whose matches are redacted.
+	Patterns []string `json:"patterns"`
+}
+
+// New returns a Redactor using the built-in detectors plus the given patterns.
+func New(patterns ...string) (*Redactor, error) {
+	r := &Redactor{detectors: append([]detector(nil), builtinDetectors...)}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		r.detectors = append(r.detectors, detector{pattern: re})
+	}
+	return r, nil
+}
+
+// DefaultConfigPath returns the path of the redaction configuration file. It
+// can be overridden with the TIDE_REDACT_FILE environment variable.
+func DefaultConfigPath() string {
+	if path := os.Getenv("TIDE_REDACT_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "redact.json")
+}
+
+// Default returns a Redactor with the built-in detectors, the patterns from
+// the user's configuration file and the values of secret-looking environment
+// variables such as OPENAI_API_KEY. If the configuration cannot be loaded, the
+// returned Redactor still applies the built-in detectors.
+func Default() (*Redactor, error) {
+	var config Config
+	var configErr error
+	if path := DefaultConfigPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &config); err != nil {
+				configErr = fmt.Errorf("invalid redaction file %s: %w", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			configErr = err
+		}
+	}
+
+	r, err := New(config.Patterns...)
+	if err != nil {
+		r, _ = New()
+		configErr = err
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if secretEnvPattern.MatchString(name) {
+			r.AddValues(value)
+		}
+	}
+	return r, configErr
+}
+
+// AddValues registers literal secret values, such as the values of a
+// project's environment variables. Values shorter than 8 characters are ignored.
+func (r *Redactor) AddValues(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if len(v) < minSecretLength {
+			continue
+		}
+		r.values = append(r.values, v)
+	}
+	// Longest first, so a value containing another is redacted as a whole.
+	sort.Slice(r.values, func(i, j int) bool { return len(r.values[i]) > len(r.values[j]) })
+}
+
+// Redact returns s with every detected secret replaced by Placeholder. A nil
+// Redactor returns s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, v := range r.values {
+		s = strings.ReplaceAll(s, v, Placeholder)
+	}
+	for _, d := range r.detectors {
+		if d.group == 0 {
+			s = d.pattern.ReplaceAllString(s, Placeholder)
+			continue
+		}
+		s = replaceGroup(d.pattern, d.group, s)
+	}
+	return s
+}
+
+// replaceGroup redacts only the given submatch of every match of re.
+func replaceGroup(re *regexp.Regexp, group int, s string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 || s[start:end] == Placeholder {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Placeholder)
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// maxPendingBytes bounds how much of an unfinished line a writer holds back.
+const maxPendingBytes = 64 * 1024
+
+// writer redacts everything written through it. Output is redacted a line at
+// a time, so that a secret split across two writes is still found.
+type writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	r       *Redactor
+	pending []byte
+}
+
+// NewWriter returns a writer that redacts secrets before writing to w. A
+// line is passed on once it is complete, or once it grows past 64KB.
+func NewWriter(w io.Writer, r *Redactor) io.Writer {
+	return &writer{w: w, r: r}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	end := bytes.LastIndexByte(w.pending, '\n') + 1
+	if end == 0 && len(w.pending) > maxPendingBytes {
+		end = len(w.pending)
+	}
+	if end == 0 {
+		return len(p), nil
+	}
+	out := w.r.Redact(string(w.pending[:end]))
+	w.pending = append(w.pending[:0], w.pending[end:]...)
+	if _, err := io.WriteString(w.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
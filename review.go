@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sgoal/tide/agent"
+)
+
+// runReview implements "tide review": it has the agent review the local
+// changes and optionally applies the fixes it suggests.
+func runReview(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	staged := flags.Bool("staged", false, "review only the staged changes")
+	base := flags.String("base", "", "review everything changed since the branch forked from this ref, e.g. main")
+	fix := flags.Bool("fix", false, "offer to apply the suggested fixes")
+	yes := flags.Bool("y", false, "with -fix, apply every fix without asking")
+	asJSON := flags.Bool("json", false, "print the review as JSON")
+	verbose := flags.Bool("v", false, "log the agent's requests to stderr")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: tide review [-staged | -base ref] [-fix [-y]] [-json] [-v] [paths...]\n\nReviews the uncommitted changes, or the given range, and lists the issues found.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *staged && *base != "" {
+		return fmt.Errorf("-staged and -base cannot be combined")
+	}
+	if *asJSON && *fix {
+		return fmt.Errorf("-json and -fix cannot be combined")
+	}
+
+	diff, err := reviewDiff(*staged, *base, flags.Args())
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Println("No changes to review.")
+		return nil
+	}
+	if !*staged {
+		if untracked, _ := gitOutput(append([]string{"ls-files", "--others", "--exclude-standard", "--"}, flags.Args()...)...); strings.TrimSpace(untracked) != "" {
+			fmt.Fprintln(os.Stderr, "Note: untracked files are not reviewed; use git add -N to include them.")
+		}
+	}
+
+	a, err := newAgent(*verbose)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	defer printUsage(a)
+
+	review, err := a.Review(diff)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(review)
+	}
+	printReview(review)
+	if *fix {
+		applyFixes(a, review.Issues, *yes)
+	}
+	return nil
+}
+
+// reviewDiff returns the diff to review: the staged changes, everything
+// since the merge base with base, or all uncommitted changes.
+func reviewDiff(staged bool, base string, paths []string) (string, error) {
+	args := []string{"diff", "--no-color", "-M"}
+	switch {
+	case staged:
+		args = append(args, "--cached")
+	case base != "":
+		if strings.HasPrefix(base, "-") {
+			return "", fmt.Errorf("invalid ref %q", base)
+		}
+		mergeBase, err := gitOutput("merge-base", base, "HEAD")
+		if err != nil {
+			return "", err
+		}
+		args = append(args, strings.TrimSpace(mergeBase))
+	default:
+		// Without commits there is no HEAD to compare against.
+		if _, err := gitOutput("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+			args = append(args, "--cached")
+		} else {
+			args = append(args, "HEAD")
+		}
+	}
+	return gitOutput(append(append(args, "--"), paths...)...)
+}
+
+func printReview(review *agent.Review) {
+	if review.Summary != "" {
+		fmt.Printf("%s\n\n", review.Summary)
+	}
+	if len(review.Issues) == 0 {
+		fmt.Println("No issues found.")
+		return
+	}
+	fmt.Printf("%d issue(s):\n", len(review.Issues))
+	for i, issue := range review.Issues {
+		fmt.Println()
+		printIssue(i+1, issue)
+	}
+}
+
+func printIssue(n int, issue agent.ReviewIssue) {
+	location := issue.File
+	if issue.Line > 0 {
+		location = fmt.Sprintf("%s:%d", issue.File, issue.Line)
+	}
+	fmt.Printf("%d. [%s] %s: %s\n", n, issue.Severity, location, issue.Title)
+	if issue.Explanation != "" {
+		fmt.Printf("%s\n", indent(issue.Explanation, "   "))
+	}
+	if issue.Patch != "" {
+		fmt.Printf("   Suggested fix:\n%s\n", indent(strings.TrimRight(issue.Patch, "\n"), "     "))
+	}
+}
+
+// applyFixes offers to fix each issue with the agent's edit tools.
+func applyFixes(a *agent.ReActAgent, issues []agent.ReviewIssue, all bool) {
+	for i, issue := range issues {
+		if !all {
+			fmt.Printf("\nFix %d. %s (%s)? [y]es, [n]o, [a]ll, [q]uit: ", i+1, issue.Title, issue.File)
+			switch ask("") {
+			case "y", "yes":
+			case "a", "all":
+				all = true
+			case "q", "quit":
+				return
+			default:
+				continue
+			}
+		}
+		result, err := a.ApplyFix(issue)
+		if err != nil {
+			fmt.Printf("Could not fix %d: %v\n", i+1, err)
+			continue
+		}
+		fmt.Printf("Fixed %d:\n%s\n", i+1, indent(result, "   "))
+	}
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
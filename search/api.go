@@ -0,0 +1,97 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SearXNG searches with a SearXNG metasearch instance through its JSON API.
+// The instance must have "json" among its enabled output formats.
+type SearXNG struct {
+	// URL is the base URL of the instance, such as https://searx.example.org.
+	URL    string
+	Client *http.Client
+}
+
+func (s *SearXNG) Name() string {
+	return "SearXNG"
+}
+
+func (s *SearXNG) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := strings.TrimRight(s.URL, "/") + "/search?format=json&q=" + url.QueryEscape(query)
+	body, err := get(ctx, s.Client, endpoint, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Results []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid SearXNG response (is the json format enabled?): %w", err)
+	}
+	var results []Result
+	for _, r := range response.Results {
+		if len(results) >= limit {
+			break
+		}
+		results = append(results, Result{Title: clean(r.Title), URL: r.URL, Snippet: htmlText(r.Content)})
+	}
+	return results, nil
+}
+
+// Brave searches with the Brave Search API.
+type Brave struct {
+	APIKey string
+	// Endpoint defaults to https://api.search.brave.com/res/v1/web/search.
+	Endpoint string
+	Client   *http.Client
+}
+
+func (b *Brave) Name() string {
+	return "Brave Search"
+}
+
+func (b *Brave) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := b.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.search.brave.com/res/v1/web/search"
+	}
+	// The API returns at most 20 results.
+	endpoint += "?q=" + url.QueryEscape(query) + "&count=" + strconv.Itoa(min(limit, 20))
+	body, err := get(ctx, b.Client, endpoint, http.Header{
+		"Accept":               {"application/json"},
+		"X-Subscription-Token": {b.APIKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("invalid Brave Search response: %w", err)
+	}
+	var results []Result
+	for _, r := range response.Web.Results {
+		if len(results) >= limit {
+			break
+		}
+		results = append(results, Result{Title: htmlText(r.Title), URL: r.URL, Snippet: htmlText(r.Description)})
+	}
+	return results, nil
+}
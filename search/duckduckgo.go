@@ -0,0 +1,156 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DuckDuckGo searches with the HTML version of DuckDuckGo, which needs no API
+// key.
+type DuckDuckGo struct {
+	// Endpoint defaults to https://html.duckduckgo.com/html/.
+	Endpoint string
+	Client   *http.Client
+}
+
+func (d *DuckDuckGo) Name() string {
+	return "DuckDuckGo"
+}
+
+func (d *DuckDuckGo) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	endpoint := d.Endpoint
+	if endpoint == "" {
+		endpoint = "https://html.duckduckgo.com/html/"
+	}
+	body, err := get(ctx, d.Client, endpoint+"?q="+url.QueryEscape(query), http.Header{
+		"User-Agent": {userAgent},
+	})
+	if err != nil {
+		return nil, err
+	}
+	root, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return parseDuckDuckGo(root, limit), nil
+}
+
+// parseDuckDuckGo extracts the results from a DuckDuckGo HTML result page.
+// Every result is an element with the class "result" holding a "result__a"
+// link and a "result__snippet"; adverts have the class "result--ad".
+func parseDuckDuckGo(root *html.Node, limit int) []Result {
+	var results []Result
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if len(results) >= limit {
+			return
+		}
+		if n.Type == html.ElementNode && hasClass(n, "result") {
+			if hasClass(n, "result--ad") {
+				return
+			}
+			link := find(n, func(c *html.Node) bool { return c.DataAtom == atom.A && hasClass(c, "result__a") })
+			if link == nil {
+				return
+			}
+			result := Result{
+				Title:   clean(text(link)),
+				URL:     duckDuckGoTarget(attr(link, "href")),
+				Snippet: clean(text(find(n, func(c *html.Node) bool { return hasClass(c, "result__snippet") }))),
+			}
+			if result.Title != "" && result.URL != "" {
+				results = append(results, result)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return results
+}
+
+// duckDuckGoTarget returns the URL a DuckDuckGo redirect link points to.
+func duckDuckGoTarget(href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if target := u.Query().Get("uddg"); target != "" && strings.HasSuffix(u.Path, "/l/") {
+		return target
+	}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// find returns the first element below n for which match returns true.
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := find(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// text returns the text of n and its descendants.
+func text(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(text(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlText returns the text of an HTML fragment, such as a snippet with
+// highlighted terms.
+func htmlText(fragment string) string {
+	if !strings.ContainsAny(fragment, "<&") {
+		return clean(fragment)
+	}
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{Type: html.ElementNode, DataAtom: atom.Div, Data: "div"})
+	if err != nil {
+		return clean(fragment)
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		b.WriteString(text(n))
+	}
+	return clean(b.String())
+}
@@ -0,0 +1,149 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxLocalFileBytes skips files too large to be documentation.
+	maxLocalFileBytes = 1024 * 1024
+	// maxLocalFiles bounds how many files one search reads.
+	maxLocalFiles = 5000
+)
+
+// localExtensions are the documentation files the local backend reads.
+var localExtensions = map[string]bool{".md": true, ".markdown": true, ".txt": true, ".rst": true}
+
+// Local searches documentation files in local directories, for use without
+// network access. Files are ranked by how many of the query terms they
+// contain and how often; the snippet is the line matching most terms.
+type Local struct {
+	Dirs []string
+}
+
+func (l *Local) Name() string {
+	return "local documentation"
+}
+
+func (l *Local) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	type scored struct {
+		Result
+		matched, count int
+	}
+	var found []scored
+	files := 0
+	for _, dir := range l.Dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == dir {
+					return err
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if name := d.Name(); path != dir && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !localExtensions[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+			if files++; files > maxLocalFiles {
+				return filepath.SkipAll
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if result, matched, count := matchFile(path, terms); matched > 0 {
+				found = append(found, scored{result, matched, count})
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].matched != found[j].matched {
+			return found[i].matched > found[j].matched
+		}
+		return found[i].count > found[j].count
+	})
+	var results []Result
+	for _, f := range found {
+		if len(results) >= limit {
+			break
+		}
+		results = append(results, f.Result)
+	}
+	return results, nil
+}
+
+// matchFile counts the query terms in a file. It returns how many distinct
+// terms matched, the total number of matches, and a result titled with the
+// file's first heading.
+func matchFile(path string, terms []string) (Result, int, int) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxLocalFileBytes {
+		return Result{}, 0, 0
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, 0, 0
+	}
+	defer f.Close()
+
+	result := Result{Title: filepath.Base(path), URL: path}
+	seen := make(map[string]bool)
+	count, best := 0, 0
+	haveTitle := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLocalFileBytes)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !haveTitle && strings.HasPrefix(line, "#") {
+			if title := strings.TrimSpace(strings.TrimLeft(line, "#")); title != "" {
+				result.Title = title
+				haveTitle = true
+			}
+		}
+		lower := strings.ToLower(line)
+		matched := 0
+		for _, term := range terms {
+			if n := strings.Count(lower, term); n > 0 {
+				seen[term] = true
+				count += n
+				matched++
+			}
+		}
+		if matched > best {
+			best = matched
+			result.Snippet = clean(line)
+		}
+	}
+	// The file name counts as a match too.
+	base := strings.ToLower(filepath.Base(path))
+	for _, term := range terms {
+		if strings.Contains(base, term) {
+			seen[term] = true
+			count++
+		}
+	}
+	if len(result.Snippet) > 300 {
+		result.Snippet = strings.ToValidUTF8(result.Snippet[:300], "") + "..."
+	}
+	return result, len(seen), count
+}
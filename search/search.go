@@ -0,0 +1,148 @@
+// Package search implements the web search backends of the search tool.
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Result is a single search result.
+type Result struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Backend is a search engine.
+type Backend interface {
+	// Name identifies the backend in tool descriptions and errors.
+	Name() string
+	// Search returns at most limit results for query, best first.
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+// userAgent is sent with the requests of the web backends. DuckDuckGo serves
+// an error page to clients it does not recognize as browsers.
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// maxResponseBytes is the most read from a search response.
+const maxResponseBytes = 4 * 1024 * 1024
+
+// Config selects and configures the search backend.
+type Config struct {
+	// Backend is "duckduckgo", "searxng", "brave" or "local". Defaults to
+	// "duckduckgo".
+	Backend string `json:"backend"`
+	// SearXNGURL is the base URL of the SearXNG instance, which must have
+	// the JSON output format enabled.
+	SearXNGURL string `json:"searxng_url,omitempty"`
+	// BraveAPIKey is the Brave Search API key. The BRAVE_API_KEY
+	// environment variable is used when it is empty.
+	BraveAPIKey string `json:"brave_api_key,omitempty"`
+	// LocalDirs are the directories the local backend searches. Defaults to
+	// the docs directory of the current directory.
+	LocalDirs []string `json:"local_dirs,omitempty"`
+}
+
+// DefaultConfigPath returns the path of the search configuration file. It can
+// be overridden with the TIDE_SEARCH_FILE environment variable.
+func DefaultConfigPath() string {
+	if path := os.Getenv("TIDE_SEARCH_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "search.json")
+}
+
+// LoadConfig reads the search configuration from path. A missing file yields
+// the default configuration.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return &Config{}, fmt.Errorf("invalid search file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// NewBackend returns the backend selected by the configuration.
+func (c *Config) NewBackend() (Backend, error) {
+	switch strings.ToLower(c.Backend) {
+	case "", "duckduckgo":
+		return &DuckDuckGo{}, nil
+	case "searxng":
+		if c.SearXNGURL == "" {
+			return nil, fmt.Errorf("the searxng backend needs searxng_url")
+		}
+		return &SearXNG{URL: c.SearXNGURL}, nil
+	case "brave":
+		key := c.BraveAPIKey
+		if key == "" {
+			key = os.Getenv("BRAVE_API_KEY")
+		}
+		if key == "" {
+			return nil, fmt.Errorf("the brave backend needs brave_api_key or the BRAVE_API_KEY environment variable")
+		}
+		return &Brave{APIKey: key}, nil
+	case "local":
+		dirs := c.LocalDirs
+		if len(dirs) == 0 {
+			dirs = []string{"docs"}
+		}
+		return &Local{Dirs: dirs}, nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", c.Backend)
+	}
+}
+
+// get sends a GET request and returns the response body, failing on
+// non-2xx responses.
+func get(ctx context.Context, client *http.Client, url string, header http.Header) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("search failed with status %s", res.Status)
+	}
+	return body, nil
+}
+
+// clean collapses the whitespace of text from a search engine.
+func clean(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
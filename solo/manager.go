@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/sgoal/tide/redact"
 	"github.com/sgoal/tide/tool"
 )
 
@@ -34,6 +35,7 @@ type SoloManager struct {
 	tools     map[string]tool.Tool
 	logWriter io.Writer
 	workspace string
+	redactor  *redact.Redactor
 }
 
 // ProjectConfig holds project configuration
@@ -51,6 +53,10 @@ func NewSoloManager(logWriter io.Writer) *SoloManager {
 	if logWriter == nil {
 		logWriter = os.Stdout
 	}
+	redactor, err := redact.Default()
+	if err != nil {
+		fmt.Fprintf(logWriter, "⚠️  加载脱敏规则失败: %v\n", err)
+	}
 
 	return &SoloManager{
 		tools: map[string]tool.Tool{
@@ -59,8 +65,9 @@ func NewSoloManager(logWriter io.Writer) *SoloManager {
 			"file_editor": &tool.FileEditorTool{},
 			"terminal":    &tool.TerminalTool{},
 		},
-		logWriter: logWriter,
+		logWriter: redact.NewWriter(logWriter, redactor),
 		workspace: getWorkspacePath(),
+		redactor:  redactor,
 	}
 }
 
@@ -73,6 +80,9 @@ func (sm *SoloManager) StartSoloMode(requirement string) error {
 	if err != nil {
 		return fmt.Errorf("解析需求失败: %w", err)
 	}
+	for _, value := range config.EnvVars {
+		sm.redactor.AddValues(value)
+	}
 
 	// Step 2: Create project structure
 	projectPath := filepath.Join(sm.workspace, config.Name)
@@ -383,6 +393,29 @@ func (sm *SoloManager) SaveConfig(projectPath string, config *ProjectConfig) err
 	return os.WriteFile(configFile, data, 0644)
 }
 
+// EnvValues returns the values of the environment variables of the SOLO
+// projects in the workspace and in the current directory, read from their
+// solo-config.json files, so that agents can redact them.
+func EnvValues() []string {
+	paths, _ := filepath.Glob(filepath.Join(getWorkspacePath(), "*", "solo-config.json"))
+	paths = append(paths, "solo-config.json")
+	var values []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var config ProjectConfig
+		if json.Unmarshal(data, &config) != nil {
+			continue
+		}
+		for _, value := range config.EnvVars {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // LoadConfig loads project configuration from file
 func (sm *SoloManager) LoadConfig(projectPath string) (*ProjectConfig, error) {
 	configFile := filepath.Join(projectPath, "solo-config.json")
@@ -393,5 +426,8 @@ func (sm *SoloManager) LoadConfig(projectPath string) (*ProjectConfig, error) {
 
 	var config ProjectConfig
 	err = json.Unmarshal(data, &config)
+	for _, value := range config.EnvVars {
+		sm.redactor.AddValues(value)
+	}
 	return &config, err
 }
@@ -0,0 +1,572 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ApplyPatchTool is a tool for applying unified diffs, possibly spanning
+// several files. Either the whole patch applies or no file is changed.
+type ApplyPatchTool struct {
+	Tracker *ReadTracker
+	// Format, when set, formats and checks each file after it is written.
+	Format *FormatConfig
+}
+
+// filePatch is the part of a unified diff that applies to one file. An empty
+// oldPath means the file is created; an empty newPath means it is deleted.
+type filePatch struct {
+	oldPath string
+	newPath string
+	hunks   []patchHunk
+}
+
+type patchHunk struct {
+	header   string
+	oldStart int
+	lines    []string
+	// noNewlineOld and noNewlineNew record "\ No newline at end of file"
+	// markers for the old and new side.
+	noNewlineOld bool
+	noNewlineNew bool
+}
+
+// patchResult is the outcome of applying a filePatch in memory.
+type patchResult struct {
+	patch   *filePatch
+	content string
+	mode    os.FileMode
+	// original is the content of the old file, kept to undo the patch.
+	original []byte
+	failures []string
+	notes    []string
+}
+
+// patchUndo restores a file changed by an applied patch.
+type patchUndo struct {
+	path string
+	// content and mode are the file's prior state; a nil content means the
+	// file did not exist.
+	content []byte
+	mode    os.FileMode
+}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+func (t *ApplyPatchTool) Name() string {
+	return "apply_patch"
+}
+
+func (t *ApplyPatchTool) Description() string {
+	return "A tool for applying a unified diff to one or more files, including creating, deleting and renaming files. Context lines are matched fuzzily. If any hunk fails, no file is changed and the failing hunks are reported."
+}
+
+func (t *ApplyPatchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"patch": {
+				"type": "string",
+				"description": "The unified diff to apply, as produced by 'diff -u' or 'git diff'. Use /dev/null as the old path to create a file and as the new path to delete one."
+			},
+			"dir": {
+				"type": "string",
+				"description": "The directory the paths in the patch are relative to. Defaults to the current directory."
+			}
+		},
+		"required": ["patch"]
+	}`)
+}
+
+func (t *ApplyPatchTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Patch string `json:"patch"`
+		Dir   string `json:"dir"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for apply_patch tool: %w", err)
+	}
+
+	patches, err := parsePatch(params.Patch)
+	if err != nil {
+		return "", err
+	}
+	// Every file the patch modifies, deletes or renames must have been read.
+	var unread []string
+	for _, p := range patches {
+		if p.oldPath == "" {
+			continue
+		}
+		if err := t.Tracker.Require(filepath.Join(params.Dir, p.oldPath)); err != nil {
+			unread = append(unread, err.Error())
+		}
+	}
+	if len(unread) > 0 {
+		return "", fmt.Errorf("the patch was not applied; no files were changed:\n%s", strings.Join(unread, "\n"))
+	}
+
+	var results []*patchResult
+	failed := false
+	for _, p := range patches {
+		result := applyFilePatch(params.Dir, p)
+		results = append(results, result)
+		if len(result.failures) > 0 {
+			failed = true
+		}
+	}
+
+	if failed {
+		var b strings.Builder
+		b.WriteString("The patch was not applied; no files were changed.\n")
+		for _, r := range results {
+			name := r.patch.displayName()
+			if len(r.failures) == 0 {
+				fmt.Fprintf(&b, "\n%s: all %d hunk(s) apply\n", name, len(r.patch.hunks))
+				continue
+			}
+			fmt.Fprintf(&b, "\n%s:\n", name)
+			for _, failure := range r.failures {
+				fmt.Fprintf(&b, "  %s\n", failure)
+			}
+		}
+		return "", fmt.Errorf("%s", strings.TrimRight(b.String(), "\n"))
+	}
+
+	// Files are written one by one; if one fails, the files already
+	// written are restored so that the patch applies as a whole or not at
+	// all.
+	var undo []patchUndo
+	var written []*patchResult
+	for _, r := range results {
+		if err := r.write(params.Dir, &undo); err != nil {
+			if rollbackErr := rollbackPatch(undo); rollbackErr != nil {
+				return "", fmt.Errorf("%w; restoring the files changed so far also failed: %v", err, rollbackErr)
+			}
+			return "", fmt.Errorf("%w; no files were changed", err)
+		}
+		written = append(written, r)
+	}
+
+	var summary []string
+	for _, r := range written {
+		summary = append(summary, t.summarize(params.Dir, r))
+	}
+	return "Patch applied successfully:\n" + strings.Join(summary, "\n"), nil
+}
+
+// write commits a successfully applied file patch to disk, recording how to
+// undo each change.
+func (r *patchResult) write(dir string, undo *[]patchUndo) error {
+	p := r.patch
+	oldPath := filepath.Join(dir, p.oldPath)
+	newPath := filepath.Join(dir, p.newPath)
+
+	if p.newPath != "" {
+		if _, err := writeFileAtomic(newPath, []byte(r.content)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", p.newPath, err)
+		}
+		if p.oldPath == p.newPath {
+			*undo = append(*undo, patchUndo{path: newPath, content: r.original, mode: r.mode})
+		} else {
+			*undo = append(*undo, patchUndo{path: newPath})
+		}
+		// New and renamed files get the mode of the file they replace.
+		if err := os.Chmod(newPath, r.mode); err != nil {
+			return fmt.Errorf("failed to set the mode of %s: %w", p.newPath, err)
+		}
+	}
+	if p.oldPath != "" && p.oldPath != p.newPath {
+		if err := os.Remove(oldPath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p.oldPath, err)
+		}
+		*undo = append(*undo, patchUndo{path: oldPath, content: r.original, mode: r.mode})
+	}
+	return nil
+}
+
+// rollbackPatch undoes the changes of a partly written patch, newest first.
+func rollbackPatch(undo []patchUndo) error {
+	var errs []string
+	for i := len(undo) - 1; i >= 0; i-- {
+		u := undo[i]
+		var err error
+		if u.content == nil {
+			if err = os.Remove(u.path); os.IsNotExist(err) {
+				err = nil
+			}
+		} else if _, err = writeFileAtomic(u.path, u.content); err == nil {
+			err = os.Chmod(u.path, u.mode)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// summarize formats and checks a written file and returns a summary line
+// for it.
+func (t *ApplyPatchTool) summarize(dir string, r *patchResult) string {
+	p := r.patch
+	if p.newPath == "" {
+		return "D " + p.oldPath
+	}
+	newPath := filepath.Join(dir, p.newPath)
+	check := t.Format.Check(newPath)
+	t.Tracker.Record(newPath)
+
+	line := ""
+	switch {
+	case p.oldPath == "":
+		line = "A " + p.newPath
+	case p.oldPath != p.newPath:
+		line = fmt.Sprintf("R %s -> %s", p.oldPath, p.newPath)
+	default:
+		line = "M " + p.newPath
+	}
+	if len(p.hunks) > 0 {
+		line += fmt.Sprintf(" (%d hunk(s))", len(p.hunks))
+	}
+	for _, note := range r.notes {
+		line += "\n    " + note
+	}
+	if check != "" {
+		line += "\n" + check
+	}
+	return line
+}
+
+func (p *filePatch) displayName() string {
+	switch {
+	case p.oldPath == "":
+		return p.newPath + " (new file)"
+	case p.newPath == "":
+		return p.oldPath + " (deleted)"
+	case p.oldPath != p.newPath:
+		return p.oldPath + " -> " + p.newPath
+	}
+	return p.newPath
+}
+
+// PatchedFiles returns the existing files a unified diff modifies, deletes
+// or renames; apply_patch requires each of them to have been read.
+func PatchedFiles(patch string) ([]string, error) {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range patches {
+		if p.oldPath != "" {
+			paths = append(paths, p.oldPath)
+		}
+	}
+	return paths, nil
+}
+
+// parsePatch splits a unified diff into per-file patches.
+func parsePatch(patch string) ([]*filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+	var patches []*filePatch
+	var current *filePatch
+	var hunk *patchHunk
+	// oldLeft and newLeft count the lines the current hunk header announces
+	// that have not been read yet. Until they are used up, lines such as
+	// "--- x" are hunk lines rather than file headers.
+	var oldLeft, newLeft int
+
+	startFile := func() {
+		current = &filePatch{}
+		patches = append(patches, current)
+		hunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case hunk != nil && oldLeft+newLeft > 0 && (line == "" || strings.ContainsRune(" -+", rune(line[0]))):
+			switch {
+			case line == "" || line[0] == ' ':
+				hunk.lines = append(hunk.lines, " "+strings.TrimPrefix(line, " "))
+				oldLeft, newLeft = max(oldLeft-1, 0), max(newLeft-1, 0)
+			case line[0] == '-':
+				hunk.lines = append(hunk.lines, line)
+				oldLeft = max(oldLeft-1, 0)
+			default:
+				hunk.lines = append(hunk.lines, line)
+				newLeft = max(newLeft-1, 0)
+			}
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			if fields := strings.Fields(line); len(fields) == 4 {
+				current.oldPath = stripPatchPrefix(fields[2], "a/")
+				current.newPath = stripPatchPrefix(fields[3], "b/")
+			}
+		case hunk == nil && current != nil && strings.HasPrefix(line, "rename from "):
+			current.oldPath = strings.TrimPrefix(line, "rename from ")
+		case hunk == nil && current != nil && strings.HasPrefix(line, "rename to "):
+			current.newPath = strings.TrimPrefix(line, "rename to ")
+		case hunk == nil && current != nil && strings.HasPrefix(line, "deleted file mode"):
+			current.newPath = ""
+		case hunk == nil && current != nil && strings.HasPrefix(line, "new file mode"):
+			current.oldPath = ""
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if current == nil || len(current.hunks) > 0 || hunk != nil {
+				startFile()
+			}
+			current.oldPath = parsePatchPath(strings.TrimPrefix(line, "--- "), "a/")
+			current.newPath = parsePatchPath(strings.TrimPrefix(lines[i+1], "+++ "), "b/")
+			hunk = nil
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk header before any file header", i+1)
+			}
+			m := hunkHeaderPattern.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", i+1, line)
+			}
+			oldStart, _ := strconv.Atoi(m[1])
+			oldLeft, newLeft = hunkLineCount(m[2]), hunkLineCount(m[4])
+			current.hunks = append(current.hunks, patchHunk{header: line, oldStart: oldStart})
+			hunk = &current.hunks[len(current.hunks)-1]
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if n := len(hunk.lines); n > 0 {
+				switch hunk.lines[n-1][0] {
+				case '-':
+					hunk.noNewlineOld = true
+				case '+':
+					hunk.noNewlineNew = true
+				default:
+					hunk.noNewlineOld = true
+					hunk.noNewlineNew = true
+				}
+			}
+		case hunk != nil && line != "" && strings.ContainsRune(" -+", rune(line[0])):
+			// Lines past the counts in the header are still taken as part
+			// of the hunk, since models often miscount.
+			hunk.lines = append(hunk.lines, line)
+		case hunk != nil && line == "":
+			// Editors and models often strip the space from empty context lines.
+			hunk.lines = append(hunk.lines, " ")
+		default:
+			hunk = nil
+		}
+	}
+
+	var result []*filePatch
+	for _, p := range patches {
+		// Trailing blank lines at the end of the patch are not context.
+		for h := range p.hunks {
+			lines := p.hunks[h].lines
+			for len(lines) > 0 && lines[len(lines)-1] == " " {
+				lines = lines[:len(lines)-1]
+			}
+			p.hunks[h].lines = lines
+		}
+		if p.oldPath == "" && p.newPath == "" {
+			continue
+		}
+		result = append(result, p)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no file changes found in the patch; expected unified diff headers ('--- old', '+++ new') and hunks ('@@ -1,3 +1,4 @@')")
+	}
+	return result, nil
+}
+
+// hunkLineCount parses a line count of a hunk header, which is 1 when
+// omitted.
+func hunkLineCount(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// parsePatchPath extracts the path from a ---/+++ header, dropping any
+// timestamp and the git a/ or b/ prefix. /dev/null yields "".
+func parsePatchPath(header, prefix string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	return stripPatchPrefix(path, prefix)
+}
+
+func stripPatchPrefix(path, prefix string) string {
+	if strings.HasPrefix(path, prefix) {
+		return path[len(prefix):]
+	}
+	return path
+}
+
+// applyFilePatch applies the hunks of p to the file on disk, in memory.
+func applyFilePatch(dir string, p *filePatch) *patchResult {
+	result := &patchResult{patch: p, mode: 0644}
+
+	var content string
+	if p.oldPath != "" {
+		path := filepath.Join(dir, p.oldPath)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			result.failures = append(result.failures, fmt.Sprintf("cannot read file: %v", err))
+			return result
+		}
+		if info, err := os.Stat(path); err == nil {
+			result.mode = info.Mode().Perm()
+		}
+		result.original = data
+		content = string(data)
+	} else if _, err := os.Stat(filepath.Join(dir, p.newPath)); err == nil {
+		result.failures = append(result.failures, "cannot create file: it already exists")
+		return result
+	}
+	if p.newPath == "" {
+		return result
+	}
+	if p.oldPath != "" && p.newPath != p.oldPath {
+		if _, err := os.Stat(filepath.Join(dir, p.newPath)); err == nil {
+			result.failures = append(result.failures, "cannot rename: the new path already exists")
+			return result
+		}
+	}
+
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	// offset tracks how far earlier hunks moved the following lines; minLine
+	// keeps hunks from overlapping.
+	offset, minLine := 0, 0
+	for i, h := range p.hunks {
+		var oldBlock []string
+		for _, line := range h.lines {
+			if line[0] != '+' {
+				oldBlock = append(oldBlock, line[1:])
+			}
+		}
+
+		expected := h.oldStart - 1 + offset
+		if len(oldBlock) == 0 {
+			// Pure insertion: the start line is the line after which to insert.
+			expected = h.oldStart + offset
+		}
+		pos, fuzz := findBlock(lines, oldBlock, expected, minLine)
+		if pos < 0 {
+			result.failures = append(result.failures, fmt.Sprintf("hunk %d (%s) failed: the context and removed lines were not found near line %d. Expected:\n%s",
+				i+1, h.header, h.oldStart, indentLines(oldBlock)))
+			continue
+		}
+		if fuzz != "" {
+			result.notes = append(result.notes, fmt.Sprintf("hunk %d applied at line %d %s", i+1, pos+1, fuzz))
+		} else if pos != h.oldStart-1 && len(oldBlock) > 0 {
+			result.notes = append(result.notes, fmt.Sprintf("hunk %d applied at line %d (offset %d)", i+1, pos+1, pos-(h.oldStart-1)))
+		}
+
+		// Context lines are kept as they are in the file, so that a fuzzy
+		// match does not rewrite their whitespace; only added lines come
+		// from the patch.
+		var newBlock []string
+		k := pos
+		for _, line := range h.lines {
+			switch line[0] {
+			case ' ':
+				newBlock = append(newBlock, lines[k])
+				k++
+			case '-':
+				k++
+			case '+':
+				newBlock = append(newBlock, line[1:])
+			}
+		}
+		updated := append([]string{}, lines[:pos]...)
+		updated = append(updated, newBlock...)
+		updated = append(updated, lines[pos+len(oldBlock):]...)
+		lines = updated
+		offset += len(newBlock) - len(oldBlock)
+		minLine = pos + len(newBlock)
+
+		if h.noNewlineNew {
+			trailingNewline = false
+		} else if h.noNewlineOld {
+			trailingNewline = true
+		}
+	}
+
+	result.content = strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result.content += "\n"
+	}
+	return result
+}
+
+// findBlock finds block in lines at or after minLine, preferring the match
+// closest to expected. Matching is exact first, then ignoring trailing
+// whitespace, then ignoring all surrounding whitespace. It returns -1 if the
+// block is not found, along with a note describing the fuzz needed.
+func findBlock(lines, block []string, expected, minLine int) (int, string) {
+	if len(block) == 0 {
+		return max(minLine, min(expected, len(lines))), ""
+	}
+
+	matchers := []struct {
+		note      string
+		normalize func(string) string
+	}{
+		{"", func(s string) string { return s }},
+		{"ignoring trailing whitespace", func(s string) string { return strings.TrimRight(s, " \t") }},
+		{"ignoring indentation", strings.TrimSpace},
+	}
+	for _, m := range matchers {
+		best := -1
+		for start := minLine; start+len(block) <= len(lines); start++ {
+			if !blockMatches(lines[start:start+len(block)], block, m.normalize) {
+				continue
+			}
+			if best < 0 || abs(start-expected) < abs(best-expected) {
+				best = start
+			}
+		}
+		if best >= 0 {
+			return best, m.note
+		}
+	}
+	return -1, ""
+}
+
+func blockMatches(lines, block []string, normalize func(string) string) bool {
+	for i := range block {
+		if normalize(lines[i]) != normalize(block[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func indentLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString("    | " + line + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeFileAtomic replaces the file at path with data by writing a temporary
+// file in the same directory and renaming it over the original, so that a
+// crash never leaves a half-written file. Missing parent directories are
+// created and the mode of an existing file is preserved. If the file existed,
+// its prior content is saved to a backup file whose path is returned.
+func writeFileAtomic(path string, data []byte) (string, error) {
+	// Write through symlinks instead of replacing them.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := os.FileMode(0644)
+	backup := ""
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a directory", path)
+		}
+		mode = info.Mode().Perm()
+		old, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read existing file: %w", err)
+		}
+		if backup, err = saveBackup(path, old); err != nil {
+			return "", fmt.Errorf("failed to back up existing file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tide-*")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp, mode); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	return backup, nil
+}
+
+// saveBackup writes the prior content of path to a new file in the user's
+// tide backup directory. Backups may hold secrets, so the directory and the
+// backup, created by os.CreateTemp with mode 0600, are private to the user.
+func saveBackup(path string, content []byte) (string, error) {
+	dir, err := backupDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%s-%s-*.bak", filepath.Base(path), time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// backupDir returns the user's backup directory.
+func backupDir() (string, error) {
+	return privateDir("backups")
+}
+
+// privateDir returns the named tide directory in the user cache directory
+// or, failing that, in a per-user directory under the temporary directory.
+// Only the user can access it.
+func privateDir(name string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = filepath.Join(os.TempDir(), fmt.Sprintf("tide-%d", os.Getuid()))
+	}
+	dir := filepath.Join(base, "tide", name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
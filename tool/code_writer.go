@@ -3,19 +3,24 @@ package tool
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 )
 
 // CodeWriterTool is a tool for writing code to a file.
-type CodeWriterTool struct{}
+type CodeWriterTool struct {
+	// Tracker, when set, requires existing files to be read before they are
+	// overwritten.
+	Tracker *ReadTracker
+	// Format, when set, formats and checks the file after it is written.
+	Format *FormatConfig
+}
 
 func (t *CodeWriterTool) Name() string {
 	return "code_writer"
 }
 
 func (t *CodeWriterTool) Description() string {
-	return "A tool for writing code to a file. The input should be a JSON object with 'dir_path', 'file_name', and 'code' keys."
+	return "A tool for writing code to a file. The input should be a JSON object with 'dir_path', 'file_name', and 'code' keys. Missing directories are created, and the previous content of an overwritten file is backed up."
 }
 
 // Execute expects args to be a JSON string with "filepath" and "code"
@@ -31,9 +36,19 @@ func (t *CodeWriterTool) Execute(args json.RawMessage) (string, error) {
 	}
 
 	filePath := filepath.Join(params.DirPath, params.FileName)
-	err = os.WriteFile(filePath, []byte(params.Code), 0644)
-	if err != nil {
+	if err := t.Tracker.Require(filePath); err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("Successfully wrote code to %s", filePath), nil
+	backup, err := writeFileAtomic(filePath, []byte(params.Code))
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	note := t.Format.Check(filePath)
+	t.Tracker.Record(filePath)
+
+	result := fmt.Sprintf("Successfully wrote code to %s", filePath)
+	if backup != "" {
+		result += fmt.Sprintf(" (previous content backed up to %s)", backup)
+	}
+	return withNote(result, note), nil
 }
@@ -0,0 +1,729 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sgoal/tide/dap"
+)
+
+const (
+	// debugBuildTimeout bounds starting Delve and building the program.
+	debugBuildTimeout = 5 * time.Minute
+	// defaultDebugWait is how long run commands wait for the program to stop.
+	defaultDebugWait    = 60 * time.Second
+	debugRequestTimeout = 30 * time.Second
+	maxStackFrames      = 50
+	maxGoroutinesShown  = 100
+	maxChildrenShown    = 50
+	// debugSourceContext is the number of source lines shown around the
+	// current line.
+	debugSourceContext = 3
+)
+
+// DebuggerTool debugs Go programs and tests with Delve, which it drives over
+// the Debug Adapter Protocol. One debug session is kept between calls.
+type DebuggerTool struct {
+	// Sandbox confines Delve and the program when set. It must allow network
+	// access, since Delve is reached over a local TCP connection.
+	Sandbox *SandboxProfile
+
+	mu      sync.Mutex
+	session *debugSession
+}
+
+// debugSession is a running Delve instance and the breakpoints set in it.
+type debugSession struct {
+	client *dap.Client
+	cancel context.CancelFunc
+	// dir is the directory paths are shown relative to.
+	dir string
+	// buildDir holds the binary Delve builds; it is removed with the
+	// session.
+	buildDir string
+	// goroutine is the goroutine that stack, locals, eval and stepping apply
+	// to.
+	goroutine int
+	sources   map[string][]dap.SourceBreakpoint
+	functions []dap.FunctionBreakpoint
+}
+
+func (t *DebuggerTool) Name() string {
+	return "debugger"
+}
+
+func (t *DebuggerTool) Description() string {
+	return "A tool for debugging Go programs and tests with Delve. 'launch' builds and starts a package (mode 'debug') or its tests (mode 'test', optionally filtered with 'run') with initial breakpoints, and runs until a breakpoint, panic or exit. Then use 'break'/'clear' to manage breakpoints ('file.go:42' or a function name like 'pkg.Func'), 'continue', 'next', 'step', 'stepout' and 'pause' to control execution, 'stack', 'goroutines', 'locals' and 'eval' to inspect state, 'output' for the program's output, and 'stop' to end the session."
+}
+
+func (t *DebuggerTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["launch", "break", "clear", "continue", "next", "step", "stepout", "pause", "stack", "goroutines", "locals", "eval", "output", "stop"],
+				"description": "The operation to perform."
+			},
+			"mode": {
+				"type": "string",
+				"enum": ["debug", "test"],
+				"description": "For 'launch': debug a main package or the tests of a package. Defaults to 'debug'."
+			},
+			"package": {
+				"type": "string",
+				"description": "For 'launch': the directory of the package. Defaults to the current directory."
+			},
+			"args": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "For 'launch': arguments passed to the program."
+			},
+			"run": {
+				"type": "string",
+				"description": "For 'launch' in test mode: only run tests matching this regular expression."
+			},
+			"build_flags": {
+				"type": "string",
+				"description": "For 'launch': extra go build flags, e.g. '-tags=integration'."
+			},
+			"breakpoints": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "For 'launch', 'break' and 'clear': locations as 'path/file.go:line' or function names like 'main.run' or 'pkg.(*T).Method'. 'clear' without breakpoints removes all of them."
+			},
+			"condition": {
+				"type": "string",
+				"description": "For 'break': a Go expression; the breakpoints only stop when it is true."
+			},
+			"expression": {
+				"type": "string",
+				"description": "For 'eval': the Go expression to evaluate, e.g. 'len(items)' or 'req.Header'."
+			},
+			"goroutine": {
+				"type": "integer",
+				"description": "The goroutine to inspect or step. Defaults to the one that stopped; the choice is kept for later calls."
+			},
+			"frame": {
+				"type": "integer",
+				"description": "For 'locals' and 'eval': the stack frame, 0 being the innermost. Defaults to 0."
+			},
+			"timeout_seconds": {
+				"type": "integer",
+				"description": "How long 'launch', 'continue' and stepping wait for the program to stop. Defaults to 60."
+			}
+		},
+		"required": ["action"]
+	}`)
+}
+
+func (t *DebuggerTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Action         string   `json:"action"`
+		Mode           string   `json:"mode"`
+		Package        string   `json:"package"`
+		Args           []string `json:"args"`
+		Run            string   `json:"run"`
+		BuildFlags     string   `json:"build_flags"`
+		Breakpoints    []string `json:"breakpoints"`
+		Condition      string   `json:"condition"`
+		Expression     string   `json:"expression"`
+		Goroutine      int      `json:"goroutine"`
+		Frame          int      `json:"frame"`
+		TimeoutSeconds int      `json:"timeout_seconds"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for debugger tool: %w", err)
+	}
+	wait := defaultDebugWait
+	if params.TimeoutSeconds > 0 {
+		wait = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if params.Action == "launch" {
+		args := params.Args
+		if params.Run != "" {
+			args = append([]string{"-test.run", params.Run}, args...)
+		}
+		return t.launch(params.Mode, params.Package, args, params.BuildFlags, params.Breakpoints, wait)
+	}
+	if params.Action == "stop" {
+		if t.session == nil {
+			return "No debug session is running.", nil
+		}
+		t.closeSession()
+		return "Debug session ended.", nil
+	}
+
+	s := t.session
+	if s == nil {
+		return "", fmt.Errorf("no debug session is running; start one with the launch action")
+	}
+	if params.Goroutine > 0 {
+		s.goroutine = params.Goroutine
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), debugRequestTimeout)
+	defer cancel()
+
+	switch params.Action {
+	case "break":
+		if len(params.Breakpoints) == 0 {
+			return "", fmt.Errorf("'breakpoints' is required for the break action")
+		}
+		return s.addBreakpoints(ctx, params.Breakpoints, params.Condition)
+	case "clear":
+		return s.clearBreakpoints(ctx, params.Breakpoints)
+	case "continue", "next", "step", "stepout":
+		return s.resume(params.Action, wait)
+	case "pause":
+		if err := s.client.Pause(ctx, max(s.goroutine, 1)); err != nil {
+			return "", err
+		}
+		return s.report(s.client.Wait(ctx)), nil
+	case "stack":
+		return s.stack(ctx)
+	case "goroutines":
+		return s.goroutines(ctx)
+	case "locals":
+		return s.locals(ctx, params.Frame)
+	case "eval":
+		if params.Expression == "" {
+			return "", fmt.Errorf("'expression' is required for the eval action")
+		}
+		return s.eval(ctx, params.Expression, params.Frame)
+	case "output":
+		output := s.client.TakeOutput()
+		if output == "" {
+			return "No new output.", nil
+		}
+		return truncateOutput(output, "debugger"), nil
+	default:
+		return "", fmt.Errorf("unknown action %q", params.Action)
+	}
+}
+
+// Close ends the debug session, if any.
+func (t *DebuggerTool) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeSession()
+	return nil
+}
+
+func (t *DebuggerTool) closeSession() {
+	if t.session == nil {
+		return
+	}
+	t.session.client.Close()
+	t.session.cancel()
+	os.RemoveAll(t.session.buildDir)
+	t.session = nil
+}
+
+func (t *DebuggerTool) launch(mode, pkg string, args []string, buildFlags string, breakpoints []string, wait time.Duration) (string, error) {
+	switch mode {
+	case "":
+		mode = "debug"
+	case "debug", "test":
+	default:
+		return "", fmt.Errorf("unknown mode %q", mode)
+	}
+	if t.Sandbox != nil && !t.Sandbox.Network {
+		return "", fmt.Errorf("the debugger talks to Delve over a local TCP connection; select a sandbox profile with network access for it")
+	}
+	if _, err := exec.LookPath("dlv"); err != nil {
+		return "", fmt.Errorf("delve is not installed; install it with 'go install github.com/go-delve/delve/cmd/dlv@latest'")
+	}
+	t.closeSession()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	if pkg == "" {
+		pkg = "."
+	}
+	program, err := filepath.Abs(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	buildDir, err := os.MkdirTemp("", "tide-debug-")
+	if err != nil {
+		return "", err
+	}
+	sessionCtx, cancel := context.WithCancel(context.Background())
+	cmd, err := sandboxedCommand(sessionCtx, t.Sandbox, dir, "dlv", "dap", "--listen=127.0.0.1:0")
+	if err != nil {
+		cancel()
+		os.RemoveAll(buildDir)
+		return "", err
+	}
+	KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	ctx, cancelLaunch := context.WithTimeout(sessionCtx, debugBuildTimeout)
+	defer cancelLaunch()
+	client, err := dap.Start(ctx, cmd)
+	if err != nil {
+		cancel()
+		os.RemoveAll(buildDir)
+		return "", err
+	}
+	s := &debugSession{
+		client:   client,
+		cancel:   cancel,
+		dir:      dir,
+		buildDir: buildDir,
+		sources:  make(map[string][]dap.SourceBreakpoint),
+	}
+	err = client.Launch(ctx, dap.LaunchConfig{
+		Mode:       mode,
+		Program:    program,
+		Args:       args,
+		BuildFlags: buildFlags,
+		Dir:        program,
+		Output:     filepath.Join(buildDir, "debug"),
+	})
+	if err != nil {
+		client.Close()
+		cancel()
+		os.RemoveAll(buildDir)
+		return "", fmt.Errorf("failed to launch %s: %w", pkg, err)
+	}
+	t.session = s
+
+	var b strings.Builder
+	if len(breakpoints) > 0 {
+		result, err := s.addBreakpoints(ctx, breakpoints, "")
+		if err != nil {
+			t.closeSession()
+			return "", err
+		}
+		b.WriteString(result + "\n")
+	}
+	if err := client.ConfigurationDone(ctx); err != nil {
+		t.closeSession()
+		return "", err
+	}
+	b.WriteString(s.waitAndReport(wait))
+	return b.String(), nil
+}
+
+// resume continues or steps the program and reports where it stopped.
+func (s *debugSession) resume(action string, wait time.Duration) (string, error) {
+	state := s.client.State()
+	if state.Terminated {
+		return "", fmt.Errorf("the program has exited; launch it again to keep debugging")
+	}
+	// A program that is still running from an earlier call is just waited
+	// for.
+	if !state.Running {
+		ctx, cancel := context.WithTimeout(context.Background(), debugRequestTimeout)
+		defer cancel()
+		thread := max(s.goroutine, 1)
+		var err error
+		switch action {
+		case "continue":
+			err = s.client.Continue(ctx, thread)
+		case "next":
+			err = s.client.Next(ctx, thread)
+		case "step":
+			err = s.client.StepIn(ctx, thread)
+		default:
+			err = s.client.StepOut(ctx, thread)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return s.waitAndReport(wait), nil
+}
+
+func (s *debugSession) waitAndReport(wait time.Duration) string {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	return s.report(s.client.Wait(ctx))
+}
+
+// report describes where the program stopped, followed by its new output.
+func (s *debugSession) report(state dap.State) string {
+	ctx, cancel := context.WithTimeout(context.Background(), debugRequestTimeout)
+	defer cancel()
+
+	var b strings.Builder
+	switch {
+	case state.Running:
+		b.WriteString("The program is still running. Use 'continue' to keep waiting or 'pause' to stop it.\n")
+	case state.Stopped != nil:
+		s.describeStop(ctx, &b, state.Stopped)
+	case state.Exited:
+		fmt.Fprintf(&b, "The program exited with code %d.\n", state.ExitCode)
+	default:
+		b.WriteString("The debug session ended.\n")
+	}
+	if output := s.client.TakeOutput(); output != "" {
+		fmt.Fprintf(&b, "\nProgram output:\n%s", output)
+	}
+	return truncateOutput(strings.TrimRight(b.String(), "\n"), "debugger")
+}
+
+func (s *debugSession) describeStop(ctx context.Context, b *strings.Builder, stop *dap.StoppedEvent) {
+	if stop.ThreadID > 0 {
+		s.goroutine = stop.ThreadID
+	} else if threads, err := s.client.Threads(ctx); err == nil && len(threads) > 0 {
+		s.goroutine = threads[0].ID
+	}
+
+	reason := stop.Reason
+	if stop.Description != "" {
+		reason = stop.Description
+	}
+	if stop.Reason == "exception" {
+		if info, err := s.client.ExceptionInfo(ctx, s.goroutine); err == nil {
+			reason = info.ExceptionID
+			if info.Description != "" {
+				reason += ": " + info.Description
+			}
+		}
+	}
+
+	frames, err := s.client.StackTrace(ctx, s.goroutine, maxStackFrames)
+	if err != nil || len(frames) == 0 {
+		fmt.Fprintf(b, "Stopped (%s) in goroutine %d.\n", reason, s.goroutine)
+		return
+	}
+	frame := frames[0]
+	fmt.Fprintf(b, "Stopped (%s) in goroutine %d at %s (%s).\n", reason, s.goroutine, frame.Name, s.location(frame))
+	if source := s.sourceContext(frame); source != "" {
+		b.WriteString(source)
+	}
+	// After a panic the innermost frames are in the runtime; the stack shows
+	// where the program's own code was.
+	if stop.Reason == "exception" {
+		b.WriteString("\nStack:\n")
+		s.writeFrames(b, frames, 15)
+	}
+}
+
+func (s *debugSession) stack(ctx context.Context) (string, error) {
+	frames, err := s.client.StackTrace(ctx, max(s.goroutine, 1), maxStackFrames)
+	if err != nil {
+		return "", err
+	}
+	if len(frames) == 0 {
+		return fmt.Sprintf("Goroutine %d has no stack frames.", s.goroutine), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Goroutine %d:\n", s.goroutine)
+	s.writeFrames(&b, frames, maxStackFrames)
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (s *debugSession) writeFrames(b *strings.Builder, frames []dap.StackFrame, limit int) {
+	for i, frame := range frames {
+		if i == limit {
+			fmt.Fprintf(b, "... and %d more frames\n", len(frames)-i)
+			break
+		}
+		fmt.Fprintf(b, "#%d %s at %s\n", i, frame.Name, s.location(frame))
+	}
+}
+
+func (s *debugSession) goroutines(ctx context.Context) (string, error) {
+	threads, err := s.client.Threads(ctx)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(threads, func(i, j int) bool { return threads[i].ID < threads[j].ID })
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s):\n", len(threads))
+	for i, thread := range threads {
+		if i == maxGoroutinesShown {
+			fmt.Fprintf(&b, "... and %d more\n", len(threads)-i)
+			break
+		}
+		marker := " "
+		if thread.ID == s.goroutine {
+			marker = "*"
+		}
+		// Delve marks the goroutine that stopped itself; the marker here is
+		// the selected one.
+		fmt.Fprintf(&b, "%s %s\n", marker, strings.TrimPrefix(thread.Name, "* "))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// frameID returns the debugger's ID of the stack frame at index.
+func (s *debugSession) frameID(ctx context.Context, index int) (int, error) {
+	frames, err := s.client.StackTrace(ctx, max(s.goroutine, 1), index+1)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= len(frames) {
+		return 0, fmt.Errorf("goroutine %d has only %d frame(s)", s.goroutine, len(frames))
+	}
+	return frames[index].ID, nil
+}
+
+func (s *debugSession) locals(ctx context.Context, frame int) (string, error) {
+	id, err := s.frameID(ctx, frame)
+	if err != nil {
+		return "", err
+	}
+	scopes, err := s.client.Scopes(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, scope := range scopes {
+		if scope.Expensive {
+			continue
+		}
+		variables, err := s.client.Variables(ctx, scope.VariablesReference)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:\n", scope.Name)
+		if len(variables) == 0 {
+			b.WriteString("  (none)\n")
+		}
+		for _, v := range variables {
+			fmt.Fprintf(&b, "  %s\n", formatVariable(v))
+		}
+	}
+	if b.Len() == 0 {
+		return "No variables in this frame.", nil
+	}
+	return truncateOutput(strings.TrimRight(b.String(), "\n"), "debugger"), nil
+}
+
+func (s *debugSession) eval(ctx context.Context, expression string, frame int) (string, error) {
+	id, err := s.frameID(ctx, frame)
+	if err != nil {
+		return "", err
+	}
+	v, err := s.client.Evaluate(ctx, expression, id)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(formatVariable(v))
+	// Show one level of the elements or fields of composite values.
+	if v.VariablesReference > 0 {
+		children, err := s.client.Variables(ctx, v.VariablesReference)
+		if err == nil {
+			for i, child := range children {
+				if i == maxChildrenShown {
+					fmt.Fprintf(&b, "\n  ... and %d more", len(children)-i)
+					break
+				}
+				fmt.Fprintf(&b, "\n  %s", formatVariable(child))
+			}
+		}
+	}
+	return truncateOutput(b.String(), "debugger"), nil
+}
+
+func formatVariable(v dap.Variable) string {
+	if v.Type == "" {
+		return fmt.Sprintf("%s = %s", v.Name, v.Value)
+	}
+	return fmt.Sprintf("%s %s = %s", v.Name, v.Type, v.Value)
+}
+
+func (s *debugSession) addBreakpoints(ctx context.Context, specs []string, condition string) (string, error) {
+	changedFiles := make(map[string]bool)
+	changedFunctions := false
+	for _, spec := range specs {
+		path, line, function, err := parseBreakpoint(spec)
+		if err != nil {
+			return "", err
+		}
+		if function != "" {
+			s.functions = append(s.functions, dap.FunctionBreakpoint{Name: function, Condition: condition})
+			changedFunctions = true
+			continue
+		}
+		s.sources[path] = append(s.sources[path], dap.SourceBreakpoint{Line: line, Condition: condition})
+		changedFiles[path] = true
+	}
+	return s.syncBreakpoints(ctx, changedFiles, changedFunctions)
+}
+
+func (s *debugSession) clearBreakpoints(ctx context.Context, specs []string) (string, error) {
+	changedFiles := make(map[string]bool)
+	changedFunctions := false
+	removed := 0
+	if len(specs) == 0 {
+		for path := range s.sources {
+			changedFiles[path] = true
+			s.sources[path] = nil
+		}
+		changedFunctions = len(s.functions) > 0
+		s.functions = nil
+	}
+	for _, spec := range specs {
+		path, line, function, err := parseBreakpoint(spec)
+		if err != nil {
+			return "", err
+		}
+		if function != "" {
+			kept := s.functions[:0]
+			for _, bp := range s.functions {
+				if bp.Name != function {
+					kept = append(kept, bp)
+				}
+			}
+			removed += len(s.functions) - len(kept)
+			s.functions = kept
+			changedFunctions = true
+			continue
+		}
+		var kept []dap.SourceBreakpoint
+		for _, bp := range s.sources[path] {
+			if bp.Line != line {
+				kept = append(kept, bp)
+			}
+		}
+		removed += len(s.sources[path]) - len(kept)
+		s.sources[path] = kept
+		changedFiles[path] = true
+	}
+	if _, err := s.syncBreakpoints(ctx, changedFiles, changedFunctions); err != nil {
+		return "", err
+	}
+	for path, breakpoints := range s.sources {
+		if len(breakpoints) == 0 {
+			delete(s.sources, path)
+		}
+	}
+	if len(specs) == 0 {
+		return "Cleared all breakpoints.", nil
+	}
+	if removed == 0 {
+		return "No matching breakpoints were set.", nil
+	}
+	return fmt.Sprintf("Cleared %d breakpoint(s).", removed), nil
+}
+
+// syncBreakpoints sends the breakpoints of the changed files and functions
+// to Delve, which replaces them, and describes how they were resolved.
+func (s *debugSession) syncBreakpoints(ctx context.Context, files map[string]bool, functions bool) (string, error) {
+	var b strings.Builder
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		resolved, err := s.client.SetBreakpoints(ctx, path, s.sources[path])
+		if err != nil {
+			return "", err
+		}
+		for i, bp := range resolved {
+			requested := dap.SourceBreakpoint{}
+			if i < len(s.sources[path]) {
+				requested = s.sources[path][i]
+			}
+			s.describeBreakpoint(&b, fmt.Sprintf("%s:%d", s.relative(path), requested.Line), bp)
+		}
+	}
+	if functions {
+		resolved, err := s.client.SetFunctionBreakpoints(ctx, s.functions)
+		if err != nil {
+			return "", err
+		}
+		for i, bp := range resolved {
+			name := ""
+			if i < len(s.functions) {
+				name = s.functions[i].Name
+			}
+			s.describeBreakpoint(&b, name, bp)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (s *debugSession) describeBreakpoint(b *strings.Builder, requested string, bp dap.Breakpoint) {
+	if !bp.Verified {
+		fmt.Fprintf(b, "Breakpoint at %s could not be set: %s\n", requested, bp.Message)
+		return
+	}
+	at := requested
+	if bp.Source != nil && bp.Source.Path != "" && bp.Line > 0 {
+		at = fmt.Sprintf("%s:%d", s.relative(bp.Source.Path), bp.Line)
+	}
+	fmt.Fprintf(b, "Breakpoint %d set at %s\n", bp.ID, at)
+}
+
+// parseBreakpoint splits a breakpoint location into an absolute file path and
+// line, or a function name.
+func parseBreakpoint(spec string) (path string, line int, function string, err error) {
+	spec = strings.TrimSpace(spec)
+	if i := strings.LastIndex(spec, ":"); i > 0 {
+		if line, err := strconv.Atoi(spec[i+1:]); err == nil {
+			if line < 1 {
+				return "", 0, "", fmt.Errorf("invalid line in breakpoint %q", spec)
+			}
+			path, err := filepath.Abs(spec[:i])
+			return path, line, "", err
+		}
+	}
+	if spec == "" {
+		return "", 0, "", fmt.Errorf("empty breakpoint location")
+	}
+	return "", 0, spec, nil
+}
+
+func (s *debugSession) location(frame dap.StackFrame) string {
+	if frame.Source == nil || frame.Source.Path == "" {
+		return "unknown location"
+	}
+	return fmt.Sprintf("%s:%d", s.relative(frame.Source.Path), frame.Line)
+}
+
+// sourceContext shows the lines around the frame's current line.
+func (s *debugSession) sourceContext(frame dap.StackFrame) string {
+	if frame.Source == nil || frame.Source.Path == "" || frame.Line < 1 {
+		return ""
+	}
+	data, err := os.ReadFile(frame.Source.Path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	if frame.Line > len(lines) {
+		return ""
+	}
+	var b strings.Builder
+	start := max(frame.Line-debugSourceContext, 1)
+	end := min(frame.Line+debugSourceContext, len(lines))
+	for n := start; n <= end; n++ {
+		marker := "  "
+		if n == frame.Line {
+			marker = "=>"
+		}
+		fmt.Fprintf(&b, "%s %4d\t%s\n", marker, n, lines[n-1])
+	}
+	return b.String()
+}
+
+func (s *debugSession) relative(path string) string {
+	if rel, err := filepath.Rel(s.dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
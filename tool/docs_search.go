@@ -0,0 +1,188 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sgoal/tide/docindex"
+)
+
+const (
+	defaultDocsResults = 5
+	maxDocsResults     = 20
+	docsIndexTimeout   = 2 * time.Minute
+	// maxDocLines caps the doc comment or section excerpt of each result.
+	maxDocLines = 15
+)
+
+// DocsSearchTool searches documentation without network access: the doc
+// comments of the Go packages the workspace depends on, read from the module
+// cache, and the markdown files in the workspace. The index is built on first
+// use and kept for the session.
+type DocsSearchTool struct {
+	// Root is the workspace to index. Defaults to the current directory.
+	Root string
+
+	mu    sync.Mutex
+	index *docindex.Index
+}
+
+func (t *DocsSearchTool) Name() string {
+	return "docs_search"
+}
+
+func (t *DocsSearchTool) Description() string {
+	return "A tool for searching documentation offline: the go doc documentation of the Go packages this workspace uses (its dependencies from the module cache, the standard library packages it imports and its own packages) and the markdown docs in the workspace. Returns matching symbols with their signature and doc comment, and excerpts of matching doc sections. Search for a symbol by name, such as 'http.Client.Do', or by words from its documentation."
+}
+
+func (t *DocsSearchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {
+				"type": "string",
+				"description": "A symbol name, such as 'http.NewRequest' or 'Client.Do', or words to search for."
+			},
+			"source": {
+				"type": "string",
+				"enum": ["all", "go", "docs"],
+				"description": "Search only Go package documentation ('go') or only markdown docs ('docs'). Defaults to 'all'."
+			},
+			"package": {
+				"type": "string",
+				"description": "Only return symbols of packages whose import path starts with this, such as 'github.com/rivo/tview'."
+			},
+			"max_results": {
+				"type": "integer",
+				"description": "The most results to return, up to 20. Defaults to 5."
+			},
+			"rebuild": {
+				"type": "boolean",
+				"description": "Rebuild the index, for example after adding a dependency or editing docs."
+			}
+		},
+		"required": ["query"]
+	}`)
+}
+
+func (t *DocsSearchTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Query      string `json:"query"`
+		Source     string `json:"source"`
+		Package    string `json:"package"`
+		MaxResults int    `json:"max_results"`
+		Rebuild    bool   `json:"rebuild"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for docs_search tool: %w", err)
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	if params.MaxResults <= 0 {
+		params.MaxResults = defaultDocsResults
+	}
+	params.MaxResults = min(params.MaxResults, maxDocsResults)
+
+	var filter func(*docindex.Entry) bool
+	switch params.Source {
+	case "", "all":
+	case "go":
+		filter = func(e *docindex.Entry) bool { return e.IsGo() }
+	case "docs":
+		filter = func(e *docindex.Entry) bool { return !e.IsGo() }
+	default:
+		return "", fmt.Errorf("unknown source %q; use all, go or docs", params.Source)
+	}
+	if params.Package != "" {
+		sourceFilter := filter
+		filter = func(e *docindex.Entry) bool {
+			return e.IsGo() && strings.HasPrefix(e.Location, params.Package) && (sourceFilter == nil || sourceFilter(e))
+		}
+	}
+
+	index, built, err := t.loadIndex(params.Rebuild)
+	if err != nil {
+		return "", err
+	}
+	hits := index.Search(params.Query, params.MaxResults, filter)
+
+	var b strings.Builder
+	if built != "" {
+		b.WriteString(built + "\n\n")
+	}
+	if len(hits) == 0 {
+		fmt.Fprintf(&b, "No documentation found for %q.", params.Query)
+		return b.String(), nil
+	}
+	for i, hit := range hits {
+		if hit.IsGo() {
+			fmt.Fprintf(&b, "%d. %s %s (%s)\n", i+1, hit.Kind, hit.Name, hit.Location)
+			b.WriteString(indentText(hit.Signature, "   ") + "\n")
+			if hit.Text != "" {
+				b.WriteString("\n" + indentText(docindex.Excerpt(hit.Text, params.Query, maxDocLines), "   ") + "\n")
+			}
+		} else {
+			fmt.Fprintf(&b, "%d. %s (%s)\n", i+1, hit.Name, hit.Location)
+			if hit.Text != "" {
+				b.WriteString(indentText(docindex.Excerpt(hit.Text, params.Query, maxDocLines), "   ") + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return truncateOutput(strings.TrimRight(b.String(), "\n"), "docs_search"), nil
+}
+
+// loadIndex returns the index, building it on first use or when rebuild is
+// set. When it was built, a note on what was indexed is returned too.
+func (t *DocsSearchTool) loadIndex(rebuild bool) (*docindex.Index, string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.index != nil && !rebuild {
+		return t.index, "", nil
+	}
+
+	root := t.Root
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, "", err
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), docsIndexTimeout)
+	defer cancel()
+	start := time.Now()
+	index, err := docindex.Build(ctx, root)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to index documentation: %w", err)
+	}
+	t.index = index
+
+	note := fmt.Sprintf("Indexed %d Go packages and %d markdown files in %s.",
+		index.Packages, index.Files, time.Since(start).Round(100*time.Millisecond))
+	if len(index.Errors) > 0 {
+		shown := index.Errors[:min(len(index.Errors), 5)]
+		note += fmt.Sprintf(" %d packages or files could not be indexed, usually because their module is not in the module cache:\n%s",
+			len(index.Errors), indentText(strings.Join(shown, "\n"), "  "))
+		if len(index.Errors) > len(shown) {
+			note += "\n  ..."
+		}
+	}
+	return index, note, nil
+}
+
+// indentText prefixes every non-empty line of text with indent.
+func indentText(text, indent string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -9,46 +9,118 @@ import (
 )
 
 // FileEditorTool is a tool for editing files.
-type FileEditorTool struct{}
+type FileEditorTool struct {
+	// Tracker, when set, requires files to be read before they are edited.
+	Tracker *ReadTracker
+	// Format, when set, formats and checks the file after it is written.
+	Format *FormatConfig
+}
+
+// FileEdit is a single search-and-replace applied by the FileEditorTool.
+type FileEdit struct {
+	SearchText  string `json:"search_text"`
+	ReplaceText string `json:"replace_text"`
+	// ReplaceAll replaces every occurrence instead of requiring the search
+	// text to match exactly once.
+	ReplaceAll bool `json:"replace_all,omitempty"`
+}
 
 func (t *FileEditorTool) Name() string {
 	return "file_editor"
 }
 
 func (t *FileEditorTool) Description() string {
-	return "A tool for reading files from a directory, modifying their content, and writing them back. Input should be a JSON object with 'dir_path', 'file_name', 'search_text', and 'replace_text'."
+	return "A tool for reading files from a directory, modifying their content, and writing them back. Input should be a JSON object with 'dir_path', 'file_name', 'search_text', and 'replace_text', or an 'edits' list of search/replace pairs applied together. The search text must match exactly once unless 'replace_all' is set."
 }
 
 func (t *FileEditorTool) Execute(args json.RawMessage) (string, error) {
 	var params struct {
-		DirPath     string `json:"dir_path"`
-		FileName    string `json:"file_name"`
-		SearchText  string `json:"search_text"`
-		ReplaceText string `json:"replace_text"`
+		DirPath  string     `json:"dir_path"`
+		FileName string     `json:"file_name"`
+		Edits    []FileEdit `json:"edits"`
+		FileEdit
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
 		return "", fmt.Errorf("invalid arguments for file_editor tool: %w", err)
 	}
+	edits := params.Edits
+	if len(edits) == 0 {
+		edits = []FileEdit{params.FileEdit}
+	}
 
 	filePath := filepath.Join(params.DirPath, params.FileName)
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		if os.IsNotExist(err) && params.SearchText == "" {
-			if err1 := os.WriteFile(filePath, []byte(params.ReplaceText), 0644); err1 != nil {
+		if os.IsNotExist(err) && len(edits) == 1 && edits[0].SearchText == "" {
+			if err := os.WriteFile(filePath, []byte(edits[0].ReplaceText), 0644); err != nil {
 				return "", fmt.Errorf("failed to create file: %w", err)
 			}
-			return fmt.Sprintf("Successfully created file: %s", filePath), nil
+			note := t.Format.Check(filePath)
+			t.Tracker.Record(filePath)
+			return withNote(fmt.Sprintf("Successfully created file: %s", filePath), note), nil
 		}
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
+	if err := t.Tracker.Require(filePath); err != nil {
+		return "", err
+	}
 
-	newContent := strings.ReplaceAll(string(content), params.SearchText, params.ReplaceText)
-	if string(content) == newContent {
-		return fmt.Sprintf("Search text not found in %s. File not modified.", filePath), nil
+	// Apply every edit in memory first, so that a failing edit leaves the
+	// file untouched.
+	newContent := string(content)
+	replaced := 0
+	for i, edit := range edits {
+		var n int
+		newContent, n, err = applyEdit(newContent, edit)
+		if err != nil {
+			if len(edits) > 1 {
+				return "", fmt.Errorf("edit %d of %d: %w; no edits were applied to %s", i+1, len(edits), err, filePath)
+			}
+			return "", fmt.Errorf("%w; %s was not modified", err, filePath)
+		}
+		replaced += n
 	}
+
 	if err := os.WriteFile(filePath, []byte(newContent), 0644); err != nil {
 		return "", fmt.Errorf("failed to write file: %w", err)
 	}
+	note := t.Format.Check(filePath)
+	t.Tracker.Record(filePath)
 
-	return fmt.Sprintf("Successfully modified file: %s", filePath), nil
+	return withNote(fmt.Sprintf("Successfully modified file: %s (%d replacement(s))", filePath, replaced), note), nil
+}
+
+// applyEdit applies edit to content and returns the new content and the
+// number of replacements made.
+func applyEdit(content string, edit FileEdit) (string, int, error) {
+	if edit.SearchText == "" {
+		return "", 0, fmt.Errorf("search text is empty")
+	}
+	count := strings.Count(content, edit.SearchText)
+	switch {
+	case count == 0:
+		return "", 0, fmt.Errorf("search text not found")
+	case count > 1 && !edit.ReplaceAll:
+		return "", 0, fmt.Errorf("search text matches %d times (at lines %s); include more surrounding context to make it unique, or set replace_all",
+			count, strings.Join(matchLines(content, edit.SearchText), ", "))
+	}
+	if edit.ReplaceAll {
+		return strings.ReplaceAll(content, edit.SearchText, edit.ReplaceText), count, nil
+	}
+	return strings.Replace(content, edit.SearchText, edit.ReplaceText, 1), 1, nil
+}
+
+// matchLines returns the line numbers at which each occurrence of search starts.
+func matchLines(content, search string) []string {
+	var lines []string
+	offset := 0
+	for {
+		i := strings.Index(content[offset:], search)
+		if i < 0 {
+			return lines
+		}
+		offset += i
+		lines = append(lines, fmt.Sprint(strings.Count(content[:offset], "\n")+1))
+		offset += len(search)
+	}
 }
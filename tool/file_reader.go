@@ -0,0 +1,229 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultReadLimit = 2000
+	maxLineLength    = 2000
+	// maxReadBytes caps a single read, however many lines were asked for.
+	maxReadBytes = 100 * 1024
+	// binarySniffBytes is how much of a file is checked for NUL bytes.
+	binarySniffBytes = 8000
+)
+
+// ReadTracker records which files the agent has read, so that edit tools can
+// refuse to change a file the agent has not seen or that changed since.
+type ReadTracker struct {
+	mu    sync.Mutex
+	files map[string]time.Time
+}
+
+// Record marks path as known to the agent in its current state.
+func (r *ReadTracker) Record(path string) {
+	if r == nil {
+		return
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files == nil {
+		r.files = make(map[string]time.Time)
+	}
+	r.files[abs] = info.ModTime()
+}
+
+// Require returns an error unless the existing file at path has been read
+// and not modified since. Files that do not exist yet need no prior read.
+func (r *ReadTracker) Require(path string) error {
+	if r == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	readAt, ok := r.files[abs]
+	if !ok {
+		return fmt.Errorf("%s has not been read yet; read it with the file_reader tool before modifying it", path)
+	}
+	if !info.ModTime().Equal(readAt) {
+		return fmt.Errorf("%s has been modified since it was last read; read it again with the file_reader tool before modifying it", path)
+	}
+	return nil
+}
+
+// FileReaderTool is a tool for reading files with line numbers.
+type FileReaderTool struct {
+	Tracker *ReadTracker
+}
+
+func (t *FileReaderTool) Name() string {
+	return "file_reader"
+}
+
+func (t *FileReaderTool) Description() string {
+	return "A tool for reading a file. Returns numbered lines; use 'offset' and 'limit' to page through large files. Files must be read before they are edited."
+}
+
+func (t *FileReaderTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {
+				"type": "string",
+				"description": "The path of the file to read."
+			},
+			"offset": {
+				"type": "integer",
+				"description": "The line number to start reading from (1-based). Defaults to 1."
+			},
+			"limit": {
+				"type": "integer",
+				"description": "The maximum number of lines to read. Defaults to 2000."
+			}
+		},
+		"required": ["path"]
+	}`)
+}
+
+func (t *FileReaderTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Path   string `json:"path"`
+		Offset int    `json:"offset"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for file_reader tool: %w", err)
+	}
+	if params.Offset < 1 {
+		params.Offset = 1
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultReadLimit
+	}
+
+	f, err := os.Open(params.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", params.Path)
+	}
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	head, _ := reader.Peek(binarySniffBytes)
+	if bytes.IndexByte(head, 0) >= 0 {
+		return fmt.Sprintf("%s is a binary file (%d bytes).", params.Path, info.Size()), nil
+	}
+	if info.Size() == 0 {
+		t.Tracker.Record(params.Path)
+		return fmt.Sprintf("%s is empty.", params.Path), nil
+	}
+
+	// Reading stops at the end of the page, so a page of a huge file costs
+	// no more than the lines up to it.
+	var b strings.Builder
+	lineNo, shown, last := 0, 0, 0
+	more := false
+	for {
+		line, cut, err := readLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		lineNo++
+		if lineNo < params.Offset {
+			continue
+		}
+		if cut {
+			line += "... [line truncated]"
+		}
+		entry := fmt.Sprintf("%6d\t%s\n", lineNo, line)
+		if b.Len()+len(entry) > maxReadBytes && shown > 0 {
+			more = true
+			break
+		}
+		b.WriteString(entry)
+		shown++
+		last = lineNo
+		if shown >= params.Limit {
+			_, err := reader.Peek(1)
+			more = err == nil
+			break
+		}
+	}
+
+	if shown == 0 {
+		return fmt.Sprintf("%s has %d lines; offset %d is past the end of the file.", params.Path, lineNo, params.Offset), nil
+	}
+	t.Tracker.Record(params.Path)
+	if more {
+		fmt.Fprintf(&b, "\n(showing lines %d-%d of a %d-byte file; use offset %d to read more)", params.Offset, last, info.Size(), last+1)
+	}
+	return b.String(), nil
+}
+
+// readLine reads the next line without its line ending. At most
+// maxLineLength bytes of the line are kept, cut at a rune boundary, so that a
+// file without newlines, such as minified code, is never held in memory
+// whole; cut reports whether the line was shortened. It returns io.EOF when
+// there are no more lines.
+func readLine(r *bufio.Reader) (line string, cut bool, err error) {
+	var buf []byte
+	read := 0
+	for {
+		chunk, err := r.ReadSlice('\n')
+		read += len(chunk)
+		if keep := maxLineLength + utf8.UTFMax - len(buf); keep > 0 {
+			buf = append(buf, chunk[:min(len(chunk), keep)]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || read == 0) {
+			return "", false, err
+		}
+		break
+	}
+	buf = bytes.TrimRight(buf, "\r\n")
+	if len(buf) > maxLineLength {
+		end := maxLineLength
+		for end > 0 && !utf8.RuneStart(buf[end]) {
+			end--
+		}
+		return string(buf[:end]), true, nil
+	}
+	return string(buf), false, nil
+}
@@ -0,0 +1,294 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/scanner"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// formatterTimeout bounds external formatters and syntax checkers.
+	formatterTimeout = 30 * time.Second
+	// maxDiagnostics caps how many problems are reported for one file.
+	maxDiagnostics = 20
+)
+
+// FormatConfig configures the formatting and syntax checks run after a tool
+// writes a file. Go files are formatted with gofmt, and Go, JSON, JavaScript
+// and HTML files are checked for syntax errors, unless DisableBuiltin is set.
+type FormatConfig struct {
+	// Formatters maps file extensions, such as ".js", to shell commands run
+	// after the built-in checks. The path of the file is passed as $1.
+	Formatters     map[string]string `json:"formatters"`
+	DisableBuiltin bool              `json:"disable_builtin"`
+}
+
+// DefaultFormatPath returns the path of the format configuration file. It can
+// be overridden with the TIDE_FORMAT_FILE environment variable.
+func DefaultFormatPath() string {
+	if path := os.Getenv("TIDE_FORMAT_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "format.json")
+}
+
+// LoadFormatConfig reads the format configuration from path. A missing file
+// yields the default configuration.
+func LoadFormatConfig(path string) (*FormatConfig, error) {
+	config := &FormatConfig{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return &FormatConfig{}, fmt.Errorf("invalid format file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Check formats and validates the file at path after it was written. It
+// returns notes and diagnostics to append to the tool observation, or "" if
+// there is nothing to report. A nil config does nothing.
+func (c *FormatConfig) Check(path string) string {
+	if c == nil {
+		return ""
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	var notes []string
+
+	if !c.DisableBuiltin {
+		if note := checkBuiltin(path, ext); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	if command, ok := c.Formatters[ext]; ok && command != "" {
+		if note := runFormatter(command, path); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	return strings.Join(notes, "\n\n")
+}
+
+func checkBuiltin(path, ext string) string {
+	switch ext {
+	case ".go", ".json", ".html", ".htm":
+	case ".js", ".mjs", ".cjs":
+		return checkJavaScript(path)
+	default:
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	switch ext {
+	case ".go":
+		return formatGo(path, data)
+	case ".json":
+		return checkJSON(path, data)
+	default:
+		return diagnostics(path, "HTML problems", checkHTML(data))
+	}
+}
+
+// formatGo rewrites the file with gofmt, or reports its syntax errors.
+func formatGo(path string, data []byte) string {
+	formatted, err := format.Source(data)
+	if err != nil {
+		var list scanner.ErrorList
+		if errors.As(err, &list) {
+			var problems []string
+			for _, e := range list {
+				problems = append(problems, fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg))
+			}
+			return diagnostics(path, "Go syntax errors", problems)
+		}
+		return diagnostics(path, "Go syntax errors", []string{err.Error()})
+	}
+	if bytes.Equal(formatted, data) {
+		return ""
+	}
+	if _, err := writeFileAtomic(path, formatted); err != nil {
+		return fmt.Sprintf("Failed to write gofmt output for %s: %v", path, err)
+	}
+	return fmt.Sprintf("Formatted %s with gofmt; re-read it before editing the changed lines.", path)
+}
+
+func checkJSON(path string, data []byte) string {
+	var v any
+	err := json.Unmarshal(data, &v)
+	if err == nil {
+		return ""
+	}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line, col := lineColumn(data, int(syntaxErr.Offset))
+		return diagnostics(path, "JSON syntax errors", []string{fmt.Sprintf("%d:%d: %s", line, col, syntaxErr.Error())})
+	}
+	return diagnostics(path, "JSON syntax errors", []string{err.Error()})
+}
+
+// checkJavaScript runs node --check when node is installed.
+func checkJavaScript(path string) string {
+	if _, err := exec.LookPath("node"); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), formatterTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "node", "--check", path).CombinedOutput()
+	if err == nil {
+		return ""
+	}
+	return diagnostics(path, "JavaScript syntax errors", strings.Split(strings.TrimSpace(string(output)), "\n"))
+}
+
+// htmlVoidElements never have end tags.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlOptionalEnd are elements whose end tags may be omitted.
+var htmlOptionalEnd = map[string]bool{
+	"html": true, "head": true, "body": true, "p": true, "li": true, "dt": true, "dd": true,
+	"tr": true, "td": true, "th": true, "thead": true, "tbody": true, "tfoot": true,
+	"option": true, "optgroup": true, "colgroup": true, "caption": true, "rb": true, "rt": true, "rtc": true, "rp": true,
+}
+
+// checkHTML reports mismatched and unclosed tags. HTML parsers accept almost
+// anything, so this catches the structural mistakes that browsers silently
+// repair in surprising ways.
+func checkHTML(data []byte) []string {
+	type openTag struct {
+		name string
+		line int
+	}
+	var stack []openTag
+	var problems []string
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	line := 1
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tagLine := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+
+		switch tt {
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if !htmlVoidElements[string(name)] {
+				stack = append(stack, openTag{string(name), tagLine})
+			}
+		case html.EndTagToken:
+			nameBytes, _ := z.TagName()
+			name := string(nameBytes)
+			i := len(stack) - 1
+			for i >= 0 && stack[i].name != name {
+				i--
+			}
+			if i < 0 {
+				if !htmlOptionalEnd[name] {
+					problems = append(problems, fmt.Sprintf("%d: </%s> has no matching start tag", tagLine, name))
+				}
+				continue
+			}
+			for _, open := range stack[i+1:] {
+				if !htmlOptionalEnd[open.name] {
+					problems = append(problems, fmt.Sprintf("%d: <%s> is not closed before </%s> on line %d", open.line, open.name, name, tagLine))
+				}
+			}
+			stack = stack[:i]
+		}
+	}
+	for _, open := range stack {
+		if !htmlOptionalEnd[open.name] {
+			problems = append(problems, fmt.Sprintf("%d: <%s> is never closed", open.line, open.name))
+		}
+	}
+	return problems
+}
+
+// runFormatter runs a user-configured formatter on path.
+func runFormatter(command, path string) string {
+	before, _ := os.ReadFile(path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), formatterTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command, "sh", path)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+		if lines[0] == "" {
+			lines = []string{"(no output)"}
+		}
+		if ctx.Err() != nil {
+			lines = append(lines, "formatter timed out")
+		}
+		return diagnostics(path, fmt.Sprintf("Formatter `%s` failed (%v)", command, err), lines)
+	}
+	after, _ := os.ReadFile(path)
+	if !bytes.Equal(before, after) {
+		return fmt.Sprintf("Formatted %s with `%s`; re-read it before editing the changed lines.", path, command)
+	}
+	return ""
+}
+
+// diagnostics formats problems found in the file at path, or returns "" if
+// there are none.
+func diagnostics(path, title string, problems []string) string {
+	if len(problems) == 0 || (len(problems) == 1 && problems[0] == "") {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s in %s:", title, path)
+	for i, problem := range problems {
+		if i == maxDiagnostics {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(problems)-i)
+			break
+		}
+		b.WriteString("\n  " + problem)
+	}
+	return b.String()
+}
+
+// withNote appends the result of a format check to a tool observation.
+func withNote(result, note string) string {
+	if note == "" {
+		return result
+	}
+	return result + "\n\n" + note
+}
+
+// lineColumn converts a byte offset in data to a 1-based line and column.
+func lineColumn(data []byte, offset int) (int, int) {
+	offset = min(max(offset, 0), len(data))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := offset - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
@@ -0,0 +1,1027 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gitTimeout      = 2 * time.Minute
+	defaultLogLimit = 20
+	maxLogLimit     = 200
+	// uncommittedHash is how git blame marks lines changed in the working tree.
+	uncommittedHash = "0000000000000000000000000000000000000000"
+)
+
+// GitTool runs version control operations and returns parsed results.
+// Operations that rewrite history or throw away work, such as force-pushes,
+// amending published commits and discarding changes, need the user's
+// approval.
+type GitTool struct {
+	mu      sync.Mutex
+	approve func(question string) bool
+}
+
+// SetApprover sets the function asked to approve risky operations. Without
+// one, they are refused.
+func (t *GitTool) SetApprover(approve func(question string) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.approve = approve
+}
+
+func (t *GitTool) Name() string {
+	return "git"
+}
+
+func (t *GitTool) Description() string {
+	return "A tool for git version control with parsed results; prefer it over running git in the terminal. Actions: 'status', 'diff' (unstaged, staged or against a ref), 'log', 'blame', 'branch' (list, create or delete), 'checkout' (switch branches or restore files), 'add', 'commit', 'stash' (push, list, show, pop, apply, drop), 'cherry_pick' and 'push'. Force-pushes, amending pushed commits, force-deleting branches, discarding changes and dropping stashes ask the user for approval."
+}
+
+func (t *GitTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["status", "diff", "log", "blame", "branch", "checkout", "add", "commit", "stash", "cherry_pick", "push"],
+				"description": "The operation to perform."
+			},
+			"dir": {
+				"type": "string",
+				"description": "The repository directory. Defaults to the current directory."
+			},
+			"paths": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Limit 'status', 'diff', 'log' and 'stash' push to these paths; the files for 'add', 'blame' (one file) and 'checkout' (restores them)."
+			},
+			"ref": {
+				"type": "string",
+				"description": "A commit, branch or range: what 'diff' compares against (e.g. 'HEAD~1' or 'main...HEAD'), where 'log' starts, the revision for 'blame', the branch to switch to or restore from with 'checkout', or the start point of a new branch."
+			},
+			"staged": {
+				"type": "boolean",
+				"description": "For 'diff': show staged changes instead of unstaged ones."
+			},
+			"stat": {
+				"type": "boolean",
+				"description": "For 'diff': only show the changed files and line counts."
+			},
+			"limit": {
+				"type": "integer",
+				"description": "For 'log': the number of commits. Defaults to 20."
+			},
+			"start_line": {
+				"type": "integer",
+				"description": "For 'blame': the first line."
+			},
+			"end_line": {
+				"type": "integer",
+				"description": "For 'blame': the last line."
+			},
+			"name": {
+				"type": "string",
+				"description": "For 'branch': the branch to create or delete. For 'checkout' with create: the new branch. For 'push': the branch to push (defaults to the current one)."
+			},
+			"create": {
+				"type": "boolean",
+				"description": "For 'checkout': create the branch 'name' (starting at 'ref') and switch to it."
+			},
+			"delete": {
+				"type": "boolean",
+				"description": "For 'branch': delete the branch 'name'."
+			},
+			"force": {
+				"type": "boolean",
+				"description": "Force-delete an unmerged branch, switch branches discarding local changes, or force-push (with lease). Requires the user's approval."
+			},
+			"message": {
+				"type": "string",
+				"description": "For 'commit': the commit message. For 'stash' push: the stash description."
+			},
+			"all": {
+				"type": "boolean",
+				"description": "For 'add': stage all changes including untracked files. For 'commit': stage modified and deleted files first. For 'branch': also list remote branches."
+			},
+			"amend": {
+				"type": "boolean",
+				"description": "For 'commit': amend the last commit. Amending a commit that was already pushed requires the user's approval."
+			},
+			"stash_action": {
+				"type": "string",
+				"enum": ["push", "list", "show", "pop", "apply", "drop"],
+				"description": "For 'stash': the stash operation. Defaults to 'push'."
+			},
+			"index": {
+				"type": "integer",
+				"description": "For 'stash' show, pop, apply and drop: the stash entry. Defaults to 0, the latest."
+			},
+			"include_untracked": {
+				"type": "boolean",
+				"description": "For 'stash' push: also stash untracked files."
+			},
+			"commits": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "For 'cherry_pick': the commits to apply, oldest first."
+			},
+			"abort": {
+				"type": "boolean",
+				"description": "For 'cherry_pick': abort the cherry-pick in progress."
+			},
+			"continue": {
+				"type": "boolean",
+				"description": "For 'cherry_pick': continue after resolving conflicts and staging the files."
+			},
+			"remote": {
+				"type": "string",
+				"description": "For 'push': the remote. Defaults to the branch's upstream remote or 'origin'."
+			},
+			"set_upstream": {
+				"type": "boolean",
+				"description": "For 'push': make the pushed branch the upstream of the local one."
+			}
+		},
+		"required": ["action"]
+	}`)
+}
+
+// gitParams are the arguments of the git tool.
+type gitParams struct {
+	Action           string   `json:"action"`
+	Dir              string   `json:"dir"`
+	Paths            []string `json:"paths"`
+	Ref              string   `json:"ref"`
+	Staged           bool     `json:"staged"`
+	Stat             bool     `json:"stat"`
+	Limit            int      `json:"limit"`
+	StartLine        int      `json:"start_line"`
+	EndLine          int      `json:"end_line"`
+	Name             string   `json:"name"`
+	Create           bool     `json:"create"`
+	Delete           bool     `json:"delete"`
+	Force            bool     `json:"force"`
+	Message          string   `json:"message"`
+	All              bool     `json:"all"`
+	Amend            bool     `json:"amend"`
+	StashAction      string   `json:"stash_action"`
+	Index            int      `json:"index"`
+	IncludeUntracked bool     `json:"include_untracked"`
+	Commits          []string `json:"commits"`
+	Abort            bool     `json:"abort"`
+	Continue         bool     `json:"continue"`
+	Remote           string   `json:"remote"`
+	SetUpstream      bool     `json:"set_upstream"`
+}
+
+func (t *GitTool) Execute(args json.RawMessage) (string, error) {
+	var p gitParams
+	if err := json.Unmarshal(args, &p); err != nil {
+		return "", fmt.Errorf("invalid arguments for git tool: %w", err)
+	}
+	// Refs and names come before "--" on the command line, so they must not
+	// be mistaken for options.
+	for _, value := range append([]string{p.Ref, p.Name, p.Remote}, p.Commits...) {
+		if strings.HasPrefix(value, "-") {
+			return "", fmt.Errorf("invalid ref or name %q", value)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	g := &gitRepo{ctx: ctx, dir: p.Dir}
+
+	var result string
+	var err error
+	switch p.Action {
+	case "status":
+		result, err = g.status(p.Paths)
+	case "diff":
+		result, err = g.diff(p)
+	case "log":
+		result, err = g.log(p)
+	case "blame":
+		result, err = g.blame(p)
+	case "branch":
+		result, err = t.branch(g, p)
+	case "checkout":
+		result, err = t.checkout(g, p)
+	case "add":
+		result, err = g.add(p)
+	case "commit":
+		result, err = t.commit(g, p)
+	case "stash":
+		result, err = t.stash(g, p)
+	case "cherry_pick":
+		result, err = g.cherryPick(p)
+	case "push":
+		result, err = t.push(g, p)
+	default:
+		return "", fmt.Errorf("unknown action %q", p.Action)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("git timed out after %s", gitTimeout)
+	}
+	return truncateOutput(result, "git"), err
+}
+
+// requireApproval asks the user to approve a risky operation.
+func (t *GitTool) requireApproval(question string) error {
+	t.mu.Lock()
+	approve := t.approve
+	t.mu.Unlock()
+	if approve == nil {
+		return fmt.Errorf("refused: %s This needs the user's approval, which cannot be asked for here; ask the user to do it themselves", question)
+	}
+	if !approve(question) {
+		return fmt.Errorf("the user declined: %s", question)
+	}
+	return nil
+}
+
+// gitRepo runs git commands in a repository.
+type gitRepo struct {
+	ctx context.Context
+	dir string
+}
+
+// run runs git with args and returns its standard output. On failure the
+// error holds git's message.
+func (g *gitRepo) run(args ...string) (string, error) {
+	cmd := g.command(args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = strings.TrimSpace(stdout.String())
+		}
+		if message == "" {
+			message = err.Error()
+		}
+		return stdout.String(), fmt.Errorf("git %s failed: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+func (g *gitRepo) command(args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir = g.dir
+	// Never wait for credentials or an editor, and keep messages parseable.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_PAGER=cat", "LC_ALL=C")
+	return cmd
+}
+
+// gitFileChange is a changed file from git status.
+type gitFileChange struct {
+	code string
+	path string
+	// from is the original path of a renamed or copied file.
+	from string
+}
+
+type gitStatus struct {
+	branch     string
+	oid        string
+	upstream   string
+	ahead      int
+	behind     int
+	staged     []gitFileChange
+	unstaged   []gitFileChange
+	untracked  []string
+	conflicted []gitFileChange
+}
+
+var gitChangeNames = map[byte]string{
+	'M': "modified",
+	'T': "type changed",
+	'A': "added",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+}
+
+var gitConflictNames = map[string]string{
+	"DD": "both deleted",
+	"AU": "added by us",
+	"UD": "deleted by them",
+	"UA": "added by them",
+	"DU": "deleted by us",
+	"AA": "both added",
+	"UU": "both modified",
+}
+
+// readStatus parses git status --porcelain=v2.
+func (g *gitRepo) readStatus(paths []string) (*gitStatus, error) {
+	args := append([]string{"status", "--porcelain=v2", "--branch", "-z", "--"}, paths...)
+	out, err := g.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	status := &gitStatus{}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		switch {
+		case strings.HasPrefix(entry, "# branch.head "):
+			status.branch = strings.TrimPrefix(entry, "# branch.head ")
+		case strings.HasPrefix(entry, "# branch.oid "):
+			status.oid = strings.TrimPrefix(entry, "# branch.oid ")
+		case strings.HasPrefix(entry, "# branch.upstream "):
+			status.upstream = strings.TrimPrefix(entry, "# branch.upstream ")
+		case strings.HasPrefix(entry, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(entry, "# branch.ab "), "+%d -%d", &status.ahead, &status.behind)
+		case strings.HasPrefix(entry, "1 "), strings.HasPrefix(entry, "2 "):
+			n := 9
+			if entry[0] == '2' {
+				n = 10
+			}
+			fields := strings.SplitN(entry, " ", n)
+			if len(fields) < n {
+				continue
+			}
+			xy, path, from := fields[1], fields[n-1], ""
+			if entry[0] == '2' && i+1 < len(entries) {
+				i++
+				from = entries[i]
+			}
+			if xy[0] != '.' {
+				status.staged = append(status.staged, gitFileChange{code: xy[:1], path: path, from: from})
+			}
+			if xy[1] != '.' {
+				status.unstaged = append(status.unstaged, gitFileChange{code: xy[1:], path: path})
+			}
+		case strings.HasPrefix(entry, "u "):
+			fields := strings.SplitN(entry, " ", 11)
+			if len(fields) == 11 {
+				status.conflicted = append(status.conflicted, gitFileChange{code: fields[1], path: fields[10]})
+			}
+		case strings.HasPrefix(entry, "? "):
+			status.untracked = append(status.untracked, entry[2:])
+		}
+	}
+	return status, nil
+}
+
+// headLine describes the current branch and how it relates to its upstream.
+func (s *gitStatus) headLine() string {
+	switch {
+	case s.branch == "(detached)":
+		return fmt.Sprintf("HEAD detached at %s", shortHash(s.oid))
+	case s.oid == "(initial)":
+		return fmt.Sprintf("On branch %s, no commits yet", s.branch)
+	case s.upstream == "":
+		return fmt.Sprintf("On branch %s, no upstream", s.branch)
+	case s.ahead == 0 && s.behind == 0:
+		return fmt.Sprintf("On branch %s, up to date with %s", s.branch, s.upstream)
+	default:
+		return fmt.Sprintf("On branch %s, tracking %s (ahead %d, behind %d)", s.branch, s.upstream, s.ahead, s.behind)
+	}
+}
+
+func (g *gitRepo) status(paths []string) (string, error) {
+	status, err := g.readStatus(paths)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(status.headLine() + "\n")
+	if operation := g.operationInProgress(); operation != "" {
+		fmt.Fprintf(&b, "%s in progress.\n", operation)
+	}
+	writeChanges := func(title string, changes []gitFileChange, names map[string]string) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s (%d):\n", title, len(changes))
+		for _, change := range changes {
+			name := names[change.code]
+			if name == "" {
+				name = gitChangeNames[change.code[0]]
+			}
+			if change.from != "" {
+				fmt.Fprintf(&b, "  %s: %s -> %s\n", name, change.from, change.path)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s\n", name, change.path)
+			}
+		}
+	}
+	writeChanges("Conflicts", status.conflicted, gitConflictNames)
+	writeChanges("Staged", status.staged, nil)
+	writeChanges("Not staged", status.unstaged, nil)
+	if len(status.untracked) > 0 {
+		fmt.Fprintf(&b, "Untracked (%d):\n", len(status.untracked))
+		for _, path := range status.untracked {
+			fmt.Fprintf(&b, "  %s\n", path)
+		}
+	}
+	if len(status.conflicted)+len(status.staged)+len(status.unstaged)+len(status.untracked) == 0 {
+		b.WriteString("Working tree clean.\n")
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// operationInProgress names an interrupted merge, cherry-pick, revert or
+// rebase, if any.
+func (g *gitRepo) operationInProgress() string {
+	out, err := g.run("rev-parse", "--git-dir")
+	if err != nil {
+		return ""
+	}
+	gitDir := strings.TrimSpace(out)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(g.dir, gitDir)
+	}
+	for _, op := range []struct{ file, name string }{
+		{"CHERRY_PICK_HEAD", "Cherry-pick"},
+		{"REVERT_HEAD", "Revert"},
+		{"MERGE_HEAD", "Merge"},
+		{"rebase-merge", "Rebase"},
+		{"rebase-apply", "Rebase"},
+	} {
+		if _, err := os.Stat(filepath.Join(gitDir, op.file)); err == nil {
+			return op.name
+		}
+	}
+	return ""
+}
+
+// diffStat summarizes git diff --numstat output.
+func diffStat(numstat string) (string, int) {
+	var b strings.Builder
+	files, added, deleted := 0, 0, 0
+	for _, line := range strings.Split(strings.TrimSpace(numstat), "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		files++
+		if fields[0] == "-" {
+			fmt.Fprintf(&b, "  binary  %s\n", fields[2])
+			continue
+		}
+		a, _ := strconv.Atoi(fields[0])
+		d, _ := strconv.Atoi(fields[1])
+		added += a
+		deleted += d
+		fmt.Fprintf(&b, "  +%-4d -%-4d %s\n", a, d, fields[2])
+	}
+	if files == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("%d file(s) changed, +%d -%d\n%s", files, added, deleted, b.String()), files
+}
+
+func (g *gitRepo) diff(p gitParams) (string, error) {
+	args := []string{"diff"}
+	what := "unstaged changes"
+	if p.Staged {
+		args = append(args, "--cached")
+		what = "staged changes"
+	}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+		what = "changes against " + p.Ref
+		if p.Staged {
+			what = "staged changes against " + p.Ref
+		}
+	}
+	paths := append([]string{"--"}, p.Paths...)
+
+	numstat, err := g.run(append(append(append([]string{}, args...), "--numstat", "-M"), paths...)...)
+	if err != nil {
+		return "", err
+	}
+	summary, files := diffStat(numstat)
+	if files == 0 {
+		return fmt.Sprintf("No %s.", what), nil
+	}
+	if p.Stat {
+		return strings.TrimRight(summary, "\n"), nil
+	}
+	patch, err := g.run(append(append(append([]string{}, args...), "-M"), paths...)...)
+	if err != nil {
+		return "", err
+	}
+	return summary + "\n" + strings.TrimRight(patch, "\n"), nil
+}
+
+func (g *gitRepo) log(p gitParams) (string, error) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+	limit = min(limit, maxLogLimit)
+	args := []string{"log", "--format=%h%x1f%an%x1f%ad%x1f%s%x1f%D%x1e", "--date=short", "-n", strconv.Itoa(limit)}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	args = append(append(args, "--"), p.Paths...)
+	out, err := g.run(args...)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	count := 0
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 5 {
+			continue
+		}
+		count++
+		fmt.Fprintf(&b, "%s %s %s: %s", fields[0], fields[2], fields[1], fields[3])
+		if fields[4] != "" {
+			fmt.Fprintf(&b, " (%s)", fields[4])
+		}
+		b.WriteString("\n")
+	}
+	if count == 0 {
+		return "No commits.", nil
+	}
+	if count == limit {
+		fmt.Fprintf(&b, "(showing the first %d commits; raise 'limit' for more)\n", limit)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (g *gitRepo) blame(p gitParams) (string, error) {
+	if len(p.Paths) != 1 {
+		return "", fmt.Errorf("'paths' must name exactly one file for the blame action")
+	}
+	args := []string{"blame", "--porcelain"}
+	if p.StartLine > 0 || p.EndLine > 0 {
+		start := max(p.StartLine, 1)
+		lineRange := fmt.Sprintf("%d,", start)
+		if p.EndLine > 0 {
+			lineRange += strconv.Itoa(p.EndLine)
+		}
+		args = append(args, "-L", lineRange)
+	}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	out, err := g.run(append(args, "--", p.Paths[0])...)
+	if err != nil {
+		return "", err
+	}
+
+	type commitInfo struct{ author, date, summary string }
+	commits := make(map[string]*commitInfo)
+	var order []string
+	var b strings.Builder
+	var hash string
+	var line int
+	for _, text := range strings.Split(out, "\n") {
+		if strings.HasPrefix(text, "\t") {
+			info := commits[hash]
+			if hash == uncommittedHash {
+				fmt.Fprintf(&b, "%5d %-31s | %s\n", line, "(uncommitted)", text[1:])
+				continue
+			}
+			fmt.Fprintf(&b, "%5d %s %-12.12s %s | %s\n", line, shortHash(hash), info.author, info.date, text[1:])
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) >= 3 && len(fields[0]) == 40 {
+			hash = fields[0]
+			line, _ = strconv.Atoi(fields[2])
+			if commits[hash] == nil {
+				commits[hash] = &commitInfo{}
+				order = append(order, hash)
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		if info := commits[hash]; info != nil {
+			switch key {
+			case "author":
+				info.author = value
+			case "author-time":
+				if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+					info.date = time.Unix(seconds, 0).UTC().Format("2006-01-02")
+				}
+			case "summary":
+				info.summary = value
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return "No lines.", nil
+	}
+
+	// The commits are listed once below the lines rather than on each line.
+	b.WriteString("\nCommits:\n")
+	for _, hash := range order {
+		if hash == uncommittedHash {
+			continue
+		}
+		info := commits[hash]
+		fmt.Fprintf(&b, "  %s %s %s: %s\n", shortHash(hash), info.date, info.author, info.summary)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (t *GitTool) branch(g *gitRepo, p gitParams) (string, error) {
+	if p.Name == "" {
+		return g.listBranches(p.All)
+	}
+	if p.Delete {
+		if _, err := g.run("branch", "-d", p.Name); err != nil {
+			if !strings.Contains(err.Error(), "not fully merged") {
+				return "", err
+			}
+			if !p.Force {
+				return "", fmt.Errorf("branch %s has commits that are not merged; set force to delete it anyway", p.Name)
+			}
+			if err := t.requireApproval(fmt.Sprintf("Delete branch %s, which has unmerged commits that will be lost?", p.Name)); err != nil {
+				return "", err
+			}
+			if _, err := g.run("branch", "-D", p.Name); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("Deleted branch %s.", p.Name), nil
+	}
+	args := []string{"branch", p.Name}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	if _, err := g.run(args...); err != nil {
+		return "", err
+	}
+	hash, _ := g.run("rev-parse", "--short", p.Name)
+	return fmt.Sprintf("Created branch %s at %s.", p.Name, strings.TrimSpace(hash)), nil
+}
+
+func (g *gitRepo) listBranches(remotes bool) (string, error) {
+	refs := []string{"refs/heads"}
+	if remotes {
+		refs = append(refs, "refs/remotes")
+	}
+	args := append([]string{"for-each-ref", "--format=%(HEAD)%1f%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(upstream:track)%1f%(contents:subject)"}, refs...)
+	out, err := g.run(args...)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) != 6 || strings.HasSuffix(fields[1], "/HEAD") {
+			continue
+		}
+		marker := " "
+		if fields[0] == "*" {
+			marker = "*"
+		}
+		fmt.Fprintf(&b, "%s %s %s", marker, fields[1], fields[2])
+		if fields[3] != "" {
+			fmt.Fprintf(&b, " [%s", fields[3])
+			if fields[4] != "" {
+				fmt.Fprintf(&b, ": %s", strings.Trim(fields[4], "[]"))
+			}
+			b.WriteString("]")
+		}
+		fmt.Fprintf(&b, " %s\n", fields[5])
+	}
+	if b.Len() == 0 {
+		return "No branches yet.", nil
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (t *GitTool) checkout(g *gitRepo, p gitParams) (string, error) {
+	if len(p.Paths) > 0 {
+		return t.restore(g, p)
+	}
+	if p.Create {
+		if p.Name == "" {
+			return "", fmt.Errorf("'name' is required to create a branch")
+		}
+		args := []string{"checkout", "-b", p.Name}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		// The trailing -- makes git take ref as a revision, never a path.
+		if _, err := g.run(append(args, "--")...); err != nil {
+			return "", err
+		}
+	} else {
+		target := p.Ref
+		if target == "" {
+			target = p.Name
+		}
+		if target == "" {
+			return "", fmt.Errorf("'ref' is required for the checkout action")
+		}
+		if strings.HasPrefix(target, "-") {
+			return "", fmt.Errorf("invalid ref %q", target)
+		}
+		args := []string{"checkout", target}
+		if p.Force {
+			if err := t.requireApproval(fmt.Sprintf("Switch to %s and discard all uncommitted changes?", target)); err != nil {
+				return "", err
+			}
+			args = []string{"checkout", "--force", target}
+		}
+		// The trailing -- makes git take target as a revision, so that a
+		// file name cannot discard that file's changes without the approval
+		// restore asks for; files are restored with 'paths'.
+		if _, err := g.run(append(args, "--")...); err != nil {
+			return "", err
+		}
+	}
+	return g.status(nil)
+}
+
+// restore checks out files from the index, or from ref when given, which
+// discards their uncommitted changes.
+func (t *GitTool) restore(g *gitRepo, p gitParams) (string, error) {
+	status, err := g.readStatus(p.Paths)
+	if err != nil {
+		return "", err
+	}
+	// Restoring from the index overwrites unstaged changes; restoring from a
+	// ref overwrites staged ones too.
+	changes := status.unstaged
+	if p.Ref != "" {
+		changes = append(changes, status.staged...)
+	}
+	var modified []string
+	for _, change := range changes {
+		modified = append(modified, change.path)
+	}
+	if len(modified) > 0 {
+		question := fmt.Sprintf("Discard the uncommitted changes to %s?", strings.Join(dedupe(modified), ", "))
+		if err := t.requireApproval(question); err != nil {
+			return "", err
+		}
+	}
+	args := []string{"checkout"}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	if _, err := g.run(append(append(args, "--"), p.Paths...)...); err != nil {
+		return "", err
+	}
+	source := "the index"
+	if p.Ref != "" {
+		source = p.Ref
+	}
+	return fmt.Sprintf("Restored %s from %s.", strings.Join(p.Paths, ", "), source), nil
+}
+
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (g *gitRepo) add(p gitParams) (string, error) {
+	args := []string{"add"}
+	switch {
+	case p.All:
+		args = append(args, "--all")
+	case len(p.Paths) == 0:
+		return "", fmt.Errorf("'paths' or 'all' is required for the add action")
+	default:
+		args = append(append(args, "--"), p.Paths...)
+	}
+	if _, err := g.run(args...); err != nil {
+		return "", err
+	}
+	return g.status(nil)
+}
+
+func (t *GitTool) commit(g *gitRepo, p gitParams) (string, error) {
+	if p.Message == "" && !p.Amend {
+		return "", fmt.Errorf("'message' is required for the commit action")
+	}
+	args := []string{"commit"}
+	if p.All {
+		args = append(args, "--all")
+	}
+	if p.Amend {
+		// Amending a commit that is already on a remote rewrites published
+		// history.
+		if remotes, err := g.run("branch", "--remotes", "--contains", "HEAD"); err == nil && strings.TrimSpace(remotes) != "" {
+			question := fmt.Sprintf("Amend commit %s, which was already pushed to %s? Pushing the result will need a force-push.", g.headSummary(), strings.Fields(remotes)[0])
+			if err := t.requireApproval(question); err != nil {
+				return "", err
+			}
+		}
+		args = append(args, "--amend")
+		if p.Message == "" {
+			args = append(args, "--no-edit")
+		}
+	}
+	if p.Message != "" {
+		args = append(args, "-m", p.Message)
+	}
+	if out, err := g.run(args...); err != nil {
+		if strings.Contains(out, "nothing to commit") || strings.Contains(out, "no changes added to commit") {
+			return "", fmt.Errorf("nothing to commit; stage changes with the add action first")
+		}
+		return "", err
+	}
+	numstat, _ := g.run("show", "--numstat", "--format=", "-M", "HEAD")
+	summary, _ := diffStat(numstat)
+	return strings.TrimRight(fmt.Sprintf("Committed %s\n%s", g.headSummary(), summary), "\n"), nil
+}
+
+// headSummary returns the short hash and subject of HEAD.
+func (g *gitRepo) headSummary() string {
+	out, err := g.run("log", "-1", "--format=%h %s")
+	if err != nil {
+		return "HEAD"
+	}
+	return strings.TrimSpace(out)
+}
+
+func (t *GitTool) stash(g *gitRepo, p gitParams) (string, error) {
+	entry := fmt.Sprintf("stash@{%d}", max(p.Index, 0))
+	switch p.StashAction {
+	case "", "push":
+		args := []string{"stash", "push"}
+		if p.IncludeUntracked {
+			args = append(args, "--include-untracked")
+		}
+		if p.Message != "" {
+			args = append(args, "-m", p.Message)
+		}
+		out, err := g.run(append(append(args, "--"), p.Paths...)...)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(out, "No local changes to save") {
+			return "No local changes to stash.", nil
+		}
+		return g.listStashes()
+	case "list":
+		return g.listStashes()
+	case "show":
+		numstat, err := g.run("stash", "show", "--numstat", "--include-untracked", entry)
+		if err != nil {
+			return "", err
+		}
+		summary, _ := diffStat(numstat)
+		patch, err := g.run("stash", "show", "--patch", entry)
+		if err != nil {
+			return "", err
+		}
+		return summary + "\n" + strings.TrimRight(patch, "\n"), nil
+	case "pop", "apply":
+		if _, err := g.run("stash", p.StashAction, entry); err != nil {
+			status, _ := g.status(nil)
+			return status, err
+		}
+		return g.status(nil)
+	case "drop":
+		description, err := g.run("stash", "list", "-n", "1", "--format=%gs", entry)
+		if err != nil {
+			return "", err
+		}
+		question := fmt.Sprintf("Drop %s (%s)? Its changes will be lost.", entry, strings.TrimSpace(description))
+		if err := t.requireApproval(question); err != nil {
+			return "", err
+		}
+		if _, err := g.run("stash", "drop", entry); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Dropped %s.", entry), nil
+	default:
+		return "", fmt.Errorf("unknown stash action %q", p.StashAction)
+	}
+}
+
+func (g *gitRepo) listStashes() (string, error) {
+	out, err := g.run("stash", "list", "--format=%gd%x1f%cr%x1f%gs")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(line, "\x1f")
+		if len(fields) == 3 {
+			fmt.Fprintf(&b, "%s (%s): %s\n", fields[0], fields[1], fields[2])
+		}
+	}
+	if b.Len() == 0 {
+		return "No stashes.", nil
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (g *gitRepo) cherryPick(p gitParams) (string, error) {
+	var args []string
+	switch {
+	case p.Abort:
+		if _, err := g.run("cherry-pick", "--abort"); err != nil {
+			return "", err
+		}
+		return "Aborted the cherry-pick.\n" + g.mustStatus(), nil
+	case p.Continue:
+		args = []string{"cherry-pick", "--continue"}
+	case len(p.Commits) == 0:
+		return "", fmt.Errorf("'commits' is required for the cherry_pick action")
+	default:
+		args = append([]string{"cherry-pick"}, p.Commits...)
+	}
+	before, _ := g.run("rev-parse", "HEAD")
+	if _, err := g.run(args...); err != nil {
+		status, _ := g.readStatus(nil)
+		if status != nil && len(status.conflicted) > 0 {
+			return "", fmt.Errorf("cherry-pick stopped on conflicts:\n%s\nResolve the conflicts, stage the files with the add action, then use cherry_pick with continue, or abort", g.mustStatus())
+		}
+		return "", fmt.Errorf("%w\n%s", err, g.mustStatus())
+	}
+	out, _ := g.run("log", "--format=%h %s", strings.TrimSpace(before)+"..HEAD")
+	return "Applied:\n" + strings.TrimRight(out, "\n"), nil
+}
+
+// mustStatus returns the status, or the error reading it.
+func (g *gitRepo) mustStatus() string {
+	status, err := g.status(nil)
+	if err != nil {
+		return err.Error()
+	}
+	return status
+}
+
+func (t *GitTool) push(g *gitRepo, p gitParams) (string, error) {
+	status, err := g.readStatus(nil)
+	if err != nil {
+		return "", err
+	}
+	branch := p.Name
+	if branch == "" {
+		if status.branch == "(detached)" {
+			return "", fmt.Errorf("HEAD is detached; name the branch to push")
+		}
+		branch = status.branch
+	}
+	remote := p.Remote
+	if remote == "" {
+		if upstream, err := g.run("config", "branch."+strings.TrimLeft(branch, "+")+".remote"); err == nil {
+			remote = strings.TrimSpace(upstream)
+		}
+	}
+	if remote == "" {
+		remote = "origin"
+	}
+
+	args := []string{"push"}
+	if p.SetUpstream {
+		args = append(args, "--set-upstream")
+	}
+	force := p.Force || strings.HasPrefix(branch, "+")
+	branch = strings.TrimPrefix(branch, "+")
+	switch {
+	case strings.HasPrefix(branch, ":"):
+		question := fmt.Sprintf("Delete branch %s from %s?", branch[1:], remote)
+		if err := t.requireApproval(question); err != nil {
+			return "", err
+		}
+	case force:
+		question := fmt.Sprintf("Force-push %s to %s? This replaces the remote branch's history.", branch, remote)
+		if err := t.requireApproval(question); err != nil {
+			return "", err
+		}
+		args = append(args, "--force-with-lease")
+	}
+	args = append(args, remote, branch)
+
+	// git push reports on stderr.
+	out, err := g.command(args...).CombinedOutput()
+	result := strings.TrimSpace(string(out))
+	if err != nil {
+		if strings.Contains(result, "[rejected]") && !force {
+			result += "\nThe remote has commits that are not in the local branch; integrate them first."
+		}
+		return "", fmt.Errorf("git push failed: %s", result)
+	}
+	return result, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const defaultGlobLimit = 200
+
+// GlobTool is a tool for finding files by name pattern.
+type GlobTool struct{}
+
+func (t *GlobTool) Name() string {
+	return "glob"
+}
+
+func (t *GlobTool) Description() string {
+	return "A tool for finding files by glob pattern, such as '**/*.go' or 'cmd/*/main.go'. Files ignored by .gitignore are skipped. Returns matching paths, one per line."
+}
+
+func (t *GlobTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"pattern": {
+				"type": "string",
+				"description": "The glob pattern. '*' matches within a path segment and '**' across directories. A pattern without '/' matches file names at any depth."
+			},
+			"path": {
+				"type": "string",
+				"description": "The directory to search. Defaults to the current directory."
+			},
+			"limit": {
+				"type": "integer",
+				"description": "The maximum number of paths to return. Defaults to 200."
+			}
+		},
+		"required": ["pattern"]
+	}`)
+}
+
+func (t *GlobTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+		Limit   int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for glob tool: %w", err)
+	}
+	if params.Pattern == "" {
+		return "", fmt.Errorf("'pattern' is required")
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultGlobLimit
+	}
+
+	pattern := strings.TrimPrefix(filepath.ToSlash(params.Pattern), "./")
+	re, err := compileGlob(pattern, strings.Contains(pattern, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid glob pattern: %w", err)
+	}
+
+	var matches []string
+	total := 0
+	err = walkFiles(params.Path, func(path, rel string) error {
+		if !re.MatchString(rel) {
+			return nil
+		}
+		total++
+		if len(matches) < params.Limit {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search files: %w", err)
+	}
+
+	if len(matches) == 0 {
+		return fmt.Sprintf("No files match %q.", params.Pattern), nil
+	}
+	result := strings.Join(matches, "\n")
+	if total > len(matches) {
+		result += fmt.Sprintf("\n\n(showing %d of %d matches; use a more specific pattern or a higher limit)", len(matches), total)
+	}
+	return result, nil
+}
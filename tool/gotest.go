@@ -0,0 +1,496 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultGoTestTimeout = 10 * time.Minute
+	// maxFailureLines caps the output shown for each failed test in the
+	// summary; the full log is available through the log action.
+	maxFailureLines = 30
+	maxSkippedShown = 20
+)
+
+// goTestLocationPattern matches file:line references in test output.
+var goTestLocationPattern = regexp.MustCompile(`([\w./\\-]+\.go):(\d+)`)
+
+// GoTestTool runs Go tests and reports structured results. The logs of the
+// last run are kept so that the agent can fetch the full output of one test.
+type GoTestTool struct {
+	// Sandbox confines the test run when set.
+	Sandbox *SandboxProfile
+
+	mu      sync.Mutex
+	lastRun *goTestRun
+}
+
+// goTestEvent is an event printed by go test -json (see go doc test2json).
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Elapsed    float64
+	Output     string
+}
+
+// goTestResult is the outcome of a single test, or of a package when test is "".
+type goTestResult struct {
+	pkg     string
+	test    string
+	action  string
+	elapsed float64
+	output  []string
+}
+
+type goTestRun struct {
+	dir     string
+	sandbox *SandboxProfile
+	// goroot is the GOROOT of the go command, set by packageDirs.
+	goroot  string
+	results map[string]*goTestResult
+	order   []string
+	// buildOutput holds compiler errors by package.
+	buildOutput map[string][]string
+	// stderr is output of the go command itself, such as package loading errors.
+	stderr string
+}
+
+func (t *GoTestTool) Name() string {
+	return "go_test"
+}
+
+func (t *GoTestTool) Description() string {
+	return "A tool for running Go tests. Reports passed, failed and skipped tests per package, with the failure output and file:line locations of failed tests. Use the 'log' action to fetch the full output of one test from the last run."
+}
+
+func (t *GoTestTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["run", "log"],
+				"description": "'run' runs tests (the default); 'log' returns the full output of one test from the last run."
+			},
+			"packages": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "The packages to test, e.g. ['./...'] or ['./agent']. Defaults to ['./...']."
+			},
+			"run": {
+				"type": "string",
+				"description": "Only run tests matching this regular expression, as with go test -run."
+			},
+			"dir": {
+				"type": "string",
+				"description": "The directory to run go test in. Defaults to the current directory."
+			},
+			"short": {
+				"type": "boolean",
+				"description": "Pass -short."
+			},
+			"race": {
+				"type": "boolean",
+				"description": "Enable the race detector."
+			},
+			"timeout_seconds": {
+				"type": "integer",
+				"description": "Kill the test run after this many seconds. Defaults to 600."
+			},
+			"test": {
+				"type": "string",
+				"description": "For 'log': the test name, e.g. TestParse or TestParse/subtest."
+			},
+			"package": {
+				"type": "string",
+				"description": "For 'log': the import path of the test's package, when the test name is ambiguous."
+			}
+		}
+	}`)
+}
+
+func (t *GoTestTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Action         string   `json:"action"`
+		Packages       []string `json:"packages"`
+		Run            string   `json:"run"`
+		Dir            string   `json:"dir"`
+		Short          bool     `json:"short"`
+		Race           bool     `json:"race"`
+		TimeoutSeconds int      `json:"timeout_seconds"`
+		Test           string   `json:"test"`
+		Package        string   `json:"package"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for go_test tool: %w", err)
+	}
+
+	switch params.Action {
+	case "", "run":
+	case "log":
+		return t.testLog(params.Package, params.Test)
+	default:
+		return "", fmt.Errorf("unknown action %q", params.Action)
+	}
+
+	testArgs := []string{"test", "-json"}
+	if params.Run != "" {
+		testArgs = append(testArgs, "-run", params.Run)
+	}
+	if params.Short {
+		testArgs = append(testArgs, "-short")
+	}
+	if params.Race {
+		testArgs = append(testArgs, "-race")
+	}
+	if len(params.Packages) == 0 {
+		params.Packages = []string{"./..."}
+	}
+	testArgs = append(testArgs, params.Packages...)
+
+	timeout := defaultGoTestTimeout
+	if params.TimeoutSeconds > 0 {
+		timeout = time.Duration(params.TimeoutSeconds) * time.Second
+	}
+	if t.Sandbox != nil && t.Sandbox.TimeoutSeconds > 0 {
+		timeout = min(timeout, time.Duration(t.Sandbox.TimeoutSeconds)*time.Second)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd, err := sandboxedCommand(ctx, t.Sandbox, params.Dir, "go", testArgs...)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	runErr := cmd.Run()
+
+	run := parseGoTestOutput(stdout.Bytes())
+	run.dir = params.Dir
+	run.sandbox = t.Sandbox
+	run.stderr = strings.TrimSpace(stderr.String())
+	t.mu.Lock()
+	t.lastRun = run
+	t.mu.Unlock()
+
+	summary := run.summary()
+	if ctx.Err() == context.DeadlineExceeded {
+		return summary, fmt.Errorf("go test timed out after %s and was killed", timeout)
+	}
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return summary, fmt.Errorf("failed to run go test: %w", runErr)
+	}
+	return summary, nil
+}
+
+// parseGoTestOutput aggregates go test -json events. Lines that are not JSON
+// events are treated as build output.
+func parseGoTestOutput(data []byte) *goTestRun {
+	run := &goTestRun{
+		results:     make(map[string]*goTestResult),
+		buildOutput: make(map[string][]string),
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var event goTestEvent
+		if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+			if text := strings.TrimRight(string(line), "\r\n"); text != "" {
+				run.buildOutput[""] = append(run.buildOutput[""], text)
+			}
+			continue
+		}
+
+		switch event.Action {
+		case "build-output":
+			run.buildOutput[event.ImportPath] = append(run.buildOutput[event.ImportPath], strings.TrimRight(event.Output, "\n"))
+			continue
+		case "build-fail", "start", "pause", "cont":
+			continue
+		}
+
+		key := event.Package + " " + event.Test
+		result, ok := run.results[key]
+		if !ok {
+			result = &goTestResult{pkg: event.Package, test: event.Test}
+			run.results[key] = result
+			run.order = append(run.order, key)
+		}
+		switch event.Action {
+		case "output":
+			result.output = append(result.output, strings.TrimRight(event.Output, "\n"))
+		case "run":
+			result.action = "run"
+		case "pass", "fail", "skip":
+			result.action = event.Action
+			result.elapsed = event.Elapsed
+		}
+	}
+	return run
+}
+
+// summary renders the results of the run for the model.
+func (r *goTestRun) summary() string {
+	var passed, failed, skipped []*goTestResult
+	var packages []*goTestResult
+	for _, key := range r.order {
+		result := r.results[key]
+		if result.test == "" {
+			packages = append(packages, result)
+			continue
+		}
+		switch result.action {
+		case "pass":
+			passed = append(passed, result)
+		case "fail", "run":
+			// A test still running when the process died failed too.
+			failed = append(failed, result)
+		case "skip":
+			skipped = append(skipped, result)
+		}
+	}
+
+	var b strings.Builder
+	failedPackages := 0
+	noTests := 0
+	for _, p := range packages {
+		switch {
+		case p.action == "fail":
+			failedPackages++
+		case p.action == "skip" || isNoTestFiles(p.output):
+			noTests++
+		}
+	}
+	status := "PASS"
+	if len(failed) > 0 || failedPackages > 0 || len(packages) == 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s: %d passed, %d failed, %d skipped in %d package(s)", status, len(passed), len(failed), len(skipped), len(packages)-noTests)
+	if noTests > 0 {
+		fmt.Fprintf(&b, " (%d without tests)", noTests)
+	}
+	b.WriteString("\n")
+
+	pkgDirs := r.packageDirs(failed)
+	for _, result := range failed {
+		if hasFailedSubtest(failed, result) && len(failureLines(result.output)) == 0 {
+			// The parent of a failed subtest adds nothing to its report.
+			continue
+		}
+		fmt.Fprintf(&b, "\n--- FAIL: %s %s (%.2fs)\n", result.pkg, result.test, result.elapsed)
+		lines := failureLines(result.output)
+		for i, line := range lines {
+			if i == maxFailureLines {
+				fmt.Fprintf(&b, "    ... %d more lines; use the log action to see them\n", len(lines)-i)
+				break
+			}
+			b.WriteString(line + "\n")
+		}
+		if locations := r.locations(result.output, pkgDirs[result.pkg]); len(locations) > 0 {
+			fmt.Fprintf(&b, "    at %s\n", strings.Join(locations, ", "))
+		}
+	}
+
+	// Packages that failed without a failing test did not build or crashed
+	// outside a test.
+	for _, p := range packages {
+		if p.action != "fail" || hasFailedTest(failed, p.pkg) {
+			continue
+		}
+		fmt.Fprintf(&b, "\n--- FAIL: package %s\n", p.pkg)
+		lines := append(r.buildOutput[p.pkg], failureLines(p.output)...)
+		for i, line := range lines {
+			if i == maxFailureLines {
+				fmt.Fprintf(&b, "    ... %d more lines\n", len(lines)-i)
+				break
+			}
+			b.WriteString("    " + strings.TrimSpace(line) + "\n")
+		}
+	}
+	if other := r.buildOutput[""]; len(other) > 0 {
+		b.WriteString("\n" + strings.Join(other, "\n") + "\n")
+	}
+	if r.stderr != "" {
+		b.WriteString("\n" + r.stderr + "\n")
+	}
+
+	if len(skipped) > 0 {
+		b.WriteString("\nSkipped:")
+		for i, result := range skipped {
+			if i == maxSkippedShown {
+				fmt.Fprintf(&b, " and %d more", len(skipped)-i)
+				break
+			}
+			fmt.Fprintf(&b, " %s", result.test)
+		}
+		b.WriteString("\n")
+	}
+	return truncateOutput(strings.TrimRight(b.String(), "\n"), "go-test")
+}
+
+// failureLines drops the framing lines go test prints around test output.
+func failureLines(output []string) []string {
+	var lines []string
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- FAIL") ||
+			strings.HasPrefix(trimmed, "--- PASS") || strings.HasPrefix(trimmed, "--- SKIP") ||
+			trimmed == "FAIL" || trimmed == "PASS" || strings.HasPrefix(trimmed, "FAIL\t") ||
+			strings.HasPrefix(trimmed, "ok  \t") || trimmed == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func isNoTestFiles(output []string) bool {
+	for _, line := range output {
+		if strings.Contains(line, "[no test files]") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFailedSubtest(failed []*goTestResult, parent *goTestResult) bool {
+	for _, result := range failed {
+		if result.pkg == parent.pkg && strings.HasPrefix(result.test, parent.test+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFailedTest(failed []*goTestResult, pkg string) bool {
+	for _, result := range failed {
+		if result.pkg == pkg {
+			return true
+		}
+	}
+	return false
+}
+
+// packageDirs looks up the source directories of the packages with failed
+// tests, so that file names in test output can be turned into paths. It also
+// records GOROOT, to tell standard library frames apart. go list runs in the
+// same sandbox as the tests.
+func (r *goTestRun) packageDirs(failed []*goTestResult) map[string]string {
+	dirs := make(map[string]string)
+	var pkgs []string
+	for _, result := range failed {
+		if _, ok := dirs[result.pkg]; !ok {
+			dirs[result.pkg] = ""
+			pkgs = append(pkgs, result.pkg)
+		}
+	}
+	if len(pkgs) == 0 {
+		return dirs
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd, err := sandboxedCommand(ctx, r.sandbox, r.dir, "go", append([]string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}\t{{context.GOROOT}}"}, pkgs...)...)
+	if err != nil {
+		return dirs
+	}
+	output, _ := cmd.Output()
+	for _, line := range strings.Split(string(output), "\n") {
+		if fields := strings.Split(line, "\t"); len(fields) == 3 {
+			dirs[fields[0]] = fields[1]
+			r.goroot = fields[2]
+		}
+	}
+	return dirs
+}
+
+// locations extracts the distinct file:line references from test output,
+// relative to the run directory when the package directory is known.
+func (r *goTestRun) locations(output []string, pkgDir string) []string {
+	base := r.dir
+	if abs, err := filepath.Abs(base); err == nil {
+		base = abs
+	}
+	seen := make(map[string]bool)
+	var locations []string
+	for _, line := range output {
+		for _, m := range goTestLocationPattern.FindAllStringSubmatch(line, -1) {
+			file := m[1]
+			if !filepath.IsAbs(file) && pkgDir != "" {
+				file = filepath.Join(pkgDir, file)
+			}
+			if filepath.IsAbs(file) {
+				if r.goroot != "" && strings.HasPrefix(file, filepath.Join(r.goroot, "src")+string(filepath.Separator)) {
+					// Frames in the standard library are rarely where the bug is.
+					continue
+				}
+				if rel, err := filepath.Rel(base, file); err == nil && !strings.HasPrefix(rel, "..") {
+					file = rel
+				}
+			}
+			location := file + ":" + m[2]
+			if !seen[location] {
+				seen[location] = true
+				locations = append(locations, location)
+			}
+		}
+	}
+	if len(locations) > 5 {
+		locations = locations[:5]
+	}
+	return locations
+}
+
+// testLog returns the full output of a test from the last run.
+func (t *GoTestTool) testLog(pkg, test string) (string, error) {
+	if test == "" {
+		return "", fmt.Errorf("'test' is required for the log action")
+	}
+	t.mu.Lock()
+	run := t.lastRun
+	t.mu.Unlock()
+	if run == nil {
+		return "", fmt.Errorf("no tests have been run yet")
+	}
+
+	var matches []*goTestResult
+	for _, key := range run.order {
+		result := run.results[key]
+		if result.test == test && (pkg == "" || result.pkg == pkg) {
+			matches = append(matches, result)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("test %s was not run in the last go test run", test)
+	case 1:
+	default:
+		var pkgs []string
+		for _, result := range matches {
+			pkgs = append(pkgs, result.pkg)
+		}
+		sort.Strings(pkgs)
+		return "", fmt.Errorf("test %s ran in several packages (%s); set 'package'", test, strings.Join(pkgs, ", "))
+	}
+
+	result := matches[0]
+	header := fmt.Sprintf("%s %s: %s (%.2fs)\n", result.pkg, result.test, result.action, result.elapsed)
+	return truncateOutput(header+strings.Join(result.output, "\n"), "go-test"), nil
+}
@@ -0,0 +1,289 @@
+package tool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultGrepLimit = 100
+	maxContextLines  = 10
+	// maxGrepFileBytes skips files too large to be source code.
+	maxGrepFileBytes = 10 * 1024 * 1024
+	// maxGrepLineLength truncates matched lines, such as minified code.
+	maxGrepLineLength = 500
+)
+
+// fileTypes maps the names accepted by the grep tool's type filter to file
+// name globs.
+var fileTypes = map[string][]string{
+	"c":          {"*.c", "*.h"},
+	"cpp":        {"*.cpp", "*.cc", "*.cxx", "*.hpp", "*.hh", "*.h"},
+	"css":        {"*.css", "*.scss", "*.sass", "*.less"},
+	"go":         {"*.go"},
+	"html":       {"*.html", "*.htm"},
+	"java":       {"*.java"},
+	"js":         {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":       {"*.json"},
+	"kotlin":     {"*.kt", "*.kts"},
+	"md":         {"*.md", "*.markdown"},
+	"proto":      {"*.proto"},
+	"py":         {"*.py", "*.pyi"},
+	"rb":         {"*.rb"},
+	"rust":       {"*.rs"},
+	"sh":         {"*.sh", "*.bash", "*.zsh"},
+	"sql":        {"*.sql"},
+	"swift":      {"*.swift"},
+	"toml":       {"*.toml"},
+	"ts":         {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"yaml":       {"*.yaml", "*.yml"},
+	"dockerfile": {"Dockerfile", "*.dockerfile"},
+}
+
+// GrepTool is a tool for searching file contents with regular expressions.
+type GrepTool struct{}
+
+func (t *GrepTool) Name() string {
+	return "grep"
+}
+
+func (t *GrepTool) Description() string {
+	return "A tool for searching file contents with a regular expression (Go RE2 syntax). Files ignored by .gitignore and binary files are skipped. Returns 'path:line:text' for matches and 'path-line-text' for context lines."
+}
+
+func (t *GrepTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"pattern": {
+				"type": "string",
+				"description": "The regular expression to search for."
+			},
+			"path": {
+				"type": "string",
+				"description": "The file or directory to search. Defaults to the current directory."
+			},
+			"glob": {
+				"type": "string",
+				"description": "Only search files matching this glob, e.g. '*.go' or 'internal/**/*.ts'."
+			},
+			"type": {
+				"type": "string",
+				"description": "Only search files of this type, e.g. go, py, js, ts, rust, java, c, cpp, md, json, yaml, sh."
+			},
+			"ignore_case": {
+				"type": "boolean",
+				"description": "Match case-insensitively."
+			},
+			"context": {
+				"type": "integer",
+				"description": "The number of lines to show before and after each match (at most 10)."
+			},
+			"files_only": {
+				"type": "boolean",
+				"description": "Return only the paths of matching files."
+			},
+			"limit": {
+				"type": "integer",
+				"description": "The maximum number of matching lines (or files, with files_only) to return. Defaults to 100."
+			}
+		},
+		"required": ["pattern"]
+	}`)
+}
+
+func (t *GrepTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Pattern    string `json:"pattern"`
+		Path       string `json:"path"`
+		Glob       string `json:"glob"`
+		Type       string `json:"type"`
+		IgnoreCase bool   `json:"ignore_case"`
+		Context    int    `json:"context"`
+		FilesOnly  bool   `json:"files_only"`
+		Limit      int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for grep tool: %w", err)
+	}
+	if params.Pattern == "" {
+		return "", fmt.Errorf("'pattern' is required")
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultGrepLimit
+	}
+	params.Context = min(max(params.Context, 0), maxContextLines)
+
+	expr := params.Pattern
+	if params.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", fmt.Errorf("invalid regular expression: %w", err)
+	}
+	filter, err := grepFilter(params.Glob, params.Type)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	matches, files := 0, 0
+	truncated := false
+	err = walkFiles(params.Path, func(file, rel string) error {
+		if filter != nil && !filter(rel) {
+			return nil
+		}
+		if matches >= params.Limit || (params.FilesOnly && files >= params.Limit) {
+			truncated = true
+			return filepath.SkipAll
+		}
+		lines, ok := readTextLines(file)
+		if !ok {
+			return nil
+		}
+
+		var hits []int
+		for i, line := range lines {
+			if re.MatchString(line) {
+				hits = append(hits, i)
+			}
+		}
+		if len(hits) == 0 {
+			return nil
+		}
+		files++
+		if params.FilesOnly {
+			b.WriteString(file + "\n")
+			return nil
+		}
+
+		if remaining := params.Limit - matches; len(hits) > remaining {
+			hits = hits[:remaining]
+			truncated = true
+		}
+		matches += len(hits)
+		writeGrepMatches(&b, file, lines, hits, params.Context)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to search files: %w", err)
+	}
+
+	if files == 0 {
+		return fmt.Sprintf("No matches for %q.", params.Pattern), nil
+	}
+	result := strings.TrimRight(b.String(), "\n")
+	if truncated {
+		result += fmt.Sprintf("\n\n(results stopped at the limit of %d; narrow the search or raise the limit)", params.Limit)
+	}
+	return truncateOutput(result, "grep"), nil
+}
+
+// grepFilter returns a predicate over relative paths implementing the glob
+// and type filters, or nil if neither is set.
+func grepFilter(glob, fileType string) (func(rel string) bool, error) {
+	var filters []*regexp.Regexp
+	if glob != "" {
+		glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+		re, err := compileGlob(glob, strings.Contains(glob, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob: %w", err)
+		}
+		filters = append(filters, re)
+	}
+
+	var typeGlobs []string
+	if fileType != "" {
+		var ok bool
+		if typeGlobs, ok = fileTypes[strings.ToLower(fileType)]; !ok {
+			names := make([]string, 0, len(fileTypes))
+			for name := range fileTypes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown file type %q; known types: %s", fileType, strings.Join(names, ", "))
+		}
+	}
+
+	if len(filters) == 0 && len(typeGlobs) == 0 {
+		return nil, nil
+	}
+	return func(rel string) bool {
+		for _, re := range filters {
+			if !re.MatchString(rel) {
+				return false
+			}
+		}
+		if len(typeGlobs) == 0 {
+			return true
+		}
+		for _, g := range typeGlobs {
+			if ok, _ := path.Match(g, path.Base(rel)); ok {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// readTextLines reads file and splits it into lines. It reports false for
+// binary, oversized and unreadable files.
+func readTextLines(file string) ([]string, bool) {
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > maxGrepFileBytes {
+		return nil, false
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffBytes)], 0) >= 0 {
+		return nil, false
+	}
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	return strings.Split(text, "\n"), true
+}
+
+// writeGrepMatches writes the matching lines of a file with their context,
+// separating non-adjacent groups with "--" like grep does.
+func writeGrepMatches(b *strings.Builder, file string, lines []string, hits []int, context int) {
+	isHit := make(map[int]bool, len(hits))
+	for _, h := range hits {
+		isHit[h] = true
+	}
+
+	last := -1
+	for _, h := range hits {
+		start := max(h-context, last+1)
+		end := min(h+context, len(lines)-1)
+		if last >= 0 && start > last+1 {
+			b.WriteString("--\n")
+		}
+		for i := start; i <= end; i++ {
+			line := lines[i]
+			if len(line) > maxGrepLineLength {
+				// Cut at a rune boundary so that no character is split.
+				cut := maxGrepLineLength
+				for cut > 0 && !utf8.RuneStart(line[cut]) {
+					cut--
+				}
+				line = line[:cut] + "... [line truncated]"
+			}
+			sep := "-"
+			if isHit[i] {
+				sep = ":"
+			}
+			fmt.Fprintf(b, "%s%s%d%s%s\n", file, sep, i+1, sep, line)
+		}
+		last = max(last, end)
+	}
+}
@@ -0,0 +1,591 @@
+package tool
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// skippedElements never contain page content.
+var skippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Form: true,
+	atom.Iframe: true, atom.Svg: true, atom.Button: true, atom.Select: true,
+	atom.Input: true, atom.Textarea: true, atom.Object: true, atom.Embed: true,
+	atom.Canvas: true, atom.Dialog: true,
+}
+
+// boilerplateRoles are ARIA roles of page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "search": true,
+	"complementary": true, "menu": true, "menubar": true, "dialog": true,
+}
+
+// boilerplateNames are class and id words that mark page chrome. A class or
+// id matches when it is the word itself or contains it as a dash-separated
+// part, so "sidebar-left" matches but "navigate" does not.
+var boilerplateNames = []string{
+	"sidebar", "navbar", "nav", "menu", "breadcrumb", "breadcrumbs", "cookie",
+	"cookies", "banner", "advert", "ads", "share", "social", "related",
+	"newsletter", "popup", "modal", "toc", "skip",
+}
+
+// htmlDocument is a page converted to markdown.
+type htmlDocument struct {
+	Title    string
+	Markdown string
+	// Next is the absolute URL of the next page of a paginated document, if
+	// the page links to one.
+	Next string
+}
+
+// convertHTML parses an HTML page and converts its main content to markdown.
+// Links and images are resolved against base, or the page's <base> element.
+func convertHTML(root *html.Node, base *url.URL) htmlDocument {
+	if href := attr(findElement(root, func(n *html.Node) bool { return n.DataAtom == atom.Base }), "href"); href != "" {
+		if u, err := base.Parse(href); err == nil {
+			base = u
+		}
+	}
+	doc := htmlDocument{}
+	if title := findElement(root, func(n *html.Node) bool { return n.DataAtom == atom.Title }); title != nil {
+		doc.Title = strings.Join(strings.Fields(textContent(title)), " ")
+	}
+	if next := findElement(root, isNextLink); next != nil {
+		if u, err := base.Parse(attr(next, "href")); err == nil {
+			u.Fragment = ""
+			doc.Next = u.String()
+		}
+	}
+
+	m := &markdownWriter{base: base, atLineStart: true}
+	content := mainContent(root)
+	// Page headers only hold site chrome outside of the main content.
+	m.article = content.DataAtom == atom.Main || content.DataAtom == atom.Article || attr(content, "role") == "main"
+	m.children(content)
+	doc.Markdown = strings.TrimSpace(m.b.String())
+	return doc
+}
+
+// mainContent returns the element holding the content of a page: its <main>
+// or <article> element, or its body.
+func mainContent(root *html.Node) *html.Node {
+	for _, match := range []func(*html.Node) bool{
+		func(n *html.Node) bool { return n.DataAtom == atom.Main },
+		func(n *html.Node) bool { return attr(n, "role") == "main" },
+		func(n *html.Node) bool { return n.DataAtom == atom.Article },
+		func(n *html.Node) bool { return n.DataAtom == atom.Body },
+	} {
+		if n := findElement(root, match); n != nil {
+			return n
+		}
+	}
+	return root
+}
+
+func isNextLink(n *html.Node) bool {
+	if n.DataAtom != atom.Link && n.DataAtom != atom.A {
+		return false
+	}
+	if attr(n, "href") == "" {
+		return false
+	}
+	for _, rel := range strings.Fields(strings.ToLower(attr(n, "rel"))) {
+		if rel == "next" {
+			return true
+		}
+	}
+	return false
+}
+
+// isBoilerplate reports whether an element is navigation, an advert or other
+// page chrome rather than content.
+func isBoilerplate(n *html.Node) bool {
+	if skippedElements[n.DataAtom] {
+		return true
+	}
+	if _, hidden := attrValue(n, "hidden"); hidden || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if boilerplateRoles[attr(n, "role")] {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+	names := strings.Fields(strings.ToLower(attr(n, "class") + " " + attr(n, "id")))
+	for _, name := range names {
+		for _, part := range strings.Split(name, "-") {
+			for _, word := range boilerplateNames {
+				if part == word {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// markdownWriter renders HTML nodes as markdown. Text is written word by word
+// so that whitespace from the HTML source collapses to single spaces, and
+// block elements only ever leave line breaks between them, never at the end.
+type markdownWriter struct {
+	b    strings.Builder
+	base *url.URL
+	// prefix is written at the start of every line, for block quotes and
+	// the continuation lines of list items.
+	prefix      []string
+	atLineStart bool
+	// pending counts the line breaks due before the next content. They are
+	// written with it; blank lines get the line prefix of the outermost
+	// block that asked for them, so a block quote does not start or end with
+	// an empty quoted line.
+	pending      int
+	pendingDepth int
+	// space is set when a space is due before the next word.
+	space bool
+	// afterMarker is set right after a list marker, where the content of
+	// the item starts without a line break.
+	afterMarker bool
+	// lists is the nesting depth of lists.
+	lists int
+	// article is set inside the main content, where headers are content.
+	article bool
+}
+
+// write writes inline markdown, preceded by the line prefix or a pending
+// space.
+func (m *markdownWriter) write(s string) {
+	if s == "" {
+		return
+	}
+	if m.pending > 0 && m.b.Len() > 0 {
+		for i := 0; i < m.pending; i++ {
+			if i > 0 {
+				m.b.WriteString(strings.TrimRight(strings.Join(m.prefix[:min(m.pendingDepth, len(m.prefix))], ""), " "))
+			}
+			m.b.WriteByte('\n')
+		}
+		m.atLineStart = true
+	}
+	m.pending = 0
+	if m.atLineStart {
+		m.b.WriteString(strings.Join(m.prefix, ""))
+		m.atLineStart = false
+	} else if m.space {
+		m.b.WriteByte(' ')
+	}
+	m.space = false
+	m.afterMarker = false
+	m.b.WriteString(s)
+}
+
+// text writes HTML text, collapsing its whitespace.
+func (m *markdownWriter) text(s string) {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" && !m.atLineStart {
+			m.space = true
+		}
+		return
+	}
+	if startsWithSpace(s) && !m.atLineStart {
+		m.space = true
+	}
+	for i, word := range words {
+		if i > 0 {
+			m.space = true
+		}
+		m.write(word)
+	}
+	if endsWithSpace(s) {
+		m.space = true
+	}
+}
+
+// newline ends the current line before the next content and, for n > 1,
+// adds blank lines, unless that many line breaks are already due.
+func (m *markdownWriter) newline(n int) {
+	if m.b.Len() == 0 || m.afterMarker {
+		return
+	}
+	m.breakAt()
+	m.pending = max(m.pending, n)
+	m.space = false
+}
+
+// breakAt records the prefix depth at which a line break was asked for.
+func (m *markdownWriter) breakAt() {
+	if m.pending == 0 {
+		m.pendingDepth = len(m.prefix)
+	}
+	m.pendingDepth = min(m.pendingDepth, len(m.prefix))
+}
+
+func (m *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		m.node(c)
+	}
+}
+
+func (m *markdownWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		m.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		m.children(n)
+		return
+	}
+	if isBoilerplate(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Header:
+		if m.article {
+			m.block(n, 1)
+		}
+	case atom.Main, atom.Article:
+		article := m.article
+		m.article = true
+		m.block(n, 2)
+		m.article = article
+	case atom.P, atom.Section, atom.Figure, atom.Details, atom.Dl:
+		m.block(n, 2)
+	case atom.Table:
+		m.table(n)
+	case atom.Div, atom.Figcaption, atom.Summary, atom.Address, atom.Center:
+		m.block(n, 1)
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		title := m.inline(n)
+		if title == "" {
+			return
+		}
+		m.newline(2)
+		m.write(strings.Repeat("#", int(n.Data[1]-'0')) + " " + title)
+		m.newline(2)
+	case atom.Br:
+		m.newline(1)
+	case atom.Hr:
+		m.newline(2)
+		m.write("---")
+		m.newline(2)
+	case atom.Pre:
+		m.pre(n)
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		m.inlineElement(n, func() string { return codeSpan(textContent(n)) })
+	case atom.Strong, atom.B:
+		m.inlineElement(n, func() string { return wrapNonEmpty("**", m.inline(n)) })
+	case atom.Em, atom.I:
+		m.inlineElement(n, func() string { return wrapNonEmpty("*", m.inline(n)) })
+	case atom.Del, atom.S, atom.Strike:
+		m.inlineElement(n, func() string { return wrapNonEmpty("~~", m.inline(n)) })
+	case atom.A:
+		m.inlineElement(n, func() string { return m.link(n) })
+	case atom.Img:
+		m.inlineElement(n, func() string { return m.image(n) })
+	case atom.Ul, atom.Ol:
+		m.list(n)
+	case atom.Li:
+		// A list item outside of a list
+		m.listItem(n, "- ")
+	case atom.Blockquote:
+		m.newline(2)
+		m.prefix = append(m.prefix, "> ")
+		m.children(n)
+		m.prefix = m.prefix[:len(m.prefix)-1]
+		m.newline(2)
+	case atom.Dt:
+		m.newline(1)
+		m.write(wrapNonEmpty("**", m.inline(n)))
+		m.newline(1)
+	case atom.Dd:
+		m.newline(1)
+		m.write(": ")
+		m.prefix = append(m.prefix, "  ")
+		m.children(n)
+		m.prefix = m.prefix[:len(m.prefix)-1]
+		m.newline(1)
+	default:
+		m.children(n)
+	}
+}
+
+// block renders the children of a block element, separated from the
+// surrounding content by n line breaks.
+func (m *markdownWriter) block(n *html.Node, breaks int) {
+	m.newline(breaks)
+	m.children(n)
+	m.newline(breaks)
+}
+
+// inlineElement writes the markdown of an inline element, keeping the
+// whitespace around its text.
+func (m *markdownWriter) inlineElement(n *html.Node, render func() string) {
+	text := textContent(n)
+	if startsWithSpace(text) && !m.atLineStart {
+		m.space = true
+	}
+	m.write(render())
+	if endsWithSpace(text) {
+		m.space = true
+	}
+}
+
+// inline renders the children of n on a single line.
+func (m *markdownWriter) inline(n *html.Node) string {
+	sub := &markdownWriter{base: m.base, atLineStart: true, article: m.article}
+	sub.children(n)
+	return strings.Join(strings.Fields(sub.b.String()), " ")
+}
+
+func (m *markdownWriter) link(n *html.Node) string {
+	text := m.inline(n)
+	href := strings.TrimSpace(attr(n, "href"))
+	if text == "" {
+		return ""
+	}
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return text
+	}
+	u, err := m.base.Parse(href)
+	if err != nil {
+		return text
+	}
+	return "[" + text + "](" + markdownURL(u.String()) + ")"
+}
+
+func (m *markdownWriter) image(n *html.Node) string {
+	alt := strings.Join(strings.Fields(attr(n, "alt")), " ")
+	src := strings.TrimSpace(attr(n, "src"))
+	// Images without a description tell the model nothing.
+	if alt == "" || src == "" || strings.HasPrefix(src, "data:") {
+		return ""
+	}
+	u, err := m.base.Parse(src)
+	if err != nil {
+		return ""
+	}
+	return "![" + alt + "](" + markdownURL(u.String()) + ")"
+}
+
+func (m *markdownWriter) pre(n *html.Node) {
+	code := strings.Trim(textContent(n), "\n")
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	language := ""
+	for _, el := range []*html.Node{n, findElement(n, func(c *html.Node) bool { return c.DataAtom == atom.Code })} {
+		for _, class := range strings.Fields(attr(el, "class")) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				language = lang
+			} else if lang, ok := strings.CutPrefix(class, "lang-"); ok {
+				language = lang
+			}
+		}
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+
+	m.newline(2)
+	m.write(fence + language)
+	// Blank lines are kept, so every line adds a line break.
+	for _, line := range strings.Split(code, "\n") {
+		m.breakAt()
+		m.pending++
+		m.write(strings.TrimRight(line, " \t\r"))
+	}
+	m.breakAt()
+	m.pending++
+	m.write(fence)
+	m.newline(2)
+}
+
+func (m *markdownWriter) list(n *html.Node) {
+	breaks := 2
+	if m.lists > 0 {
+		breaks = 1
+	}
+	m.newline(breaks)
+	m.lists++
+	number := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		number = start
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li || isBoilerplate(c) {
+			if c.Type == html.ElementNode {
+				m.node(c)
+			}
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		m.listItem(c, marker)
+	}
+	m.lists--
+	m.newline(breaks)
+}
+
+// listItem writes a list item, indenting its continuation lines under its
+// text.
+func (m *markdownWriter) listItem(n *html.Node, marker string) {
+	m.newline(1)
+	m.write(marker)
+	m.afterMarker = true
+	m.prefix = append(m.prefix, strings.Repeat(" ", len(marker)))
+	m.children(n)
+	m.prefix = m.prefix[:len(m.prefix)-1]
+	m.newline(1)
+}
+
+// table writes a table as a markdown table. The first row is the header.
+func (m *markdownWriter) table(n *html.Node) {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						cells = append(cells, strings.ReplaceAll(m.inline(cell), "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return
+	}
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+
+	m.newline(2)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		m.write("| " + strings.Join(row, " | ") + " |")
+		m.newline(1)
+		if i == 0 {
+			m.write("|" + strings.Repeat(" --- |", columns))
+			m.newline(1)
+		}
+	}
+	m.newline(2)
+}
+
+// findElement returns the first element below n, in document order, for which
+// match returns true.
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && match(c) {
+			return c
+		}
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent returns the text of n and its descendants, without scripts and
+// styles.
+func textContent(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	if n.DataAtom == atom.Script || n.DataAtom == atom.Style {
+		return ""
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func attr(n *html.Node, name string) string {
+	value, _ := attrValue(n, name)
+	return value
+}
+
+func attrValue(n *html.Node, name string) (string, bool) {
+	if n == nil {
+		return "", false
+	}
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == name {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// codeSpan formats text as inline code, using a longer run of backticks when
+// the text contains backticks itself.
+func codeSpan(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return ""
+	}
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if fence != "`" || strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		return fence + " " + text + " " + fence
+	}
+	return fence + text + fence
+}
+
+func wrapNonEmpty(marker, text string) string {
+	if text == "" {
+		return ""
+	}
+	return marker + text + marker
+}
+
+// markdownURL escapes the characters of a URL that would end a markdown link.
+func markdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u)
+}
+
+func startsWithSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func endsWithSpace(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,286 @@
+package tool
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single pattern from a .gitignore file.
+type ignoreRule struct {
+	// base is the directory of the .gitignore file, relative to the
+	// repository root.
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreMatcher applies .gitignore rules in the order git does: later rules,
+// and rules from deeper directories, override earlier ones.
+type ignoreMatcher struct {
+	rules []ignoreRule
+	// prefix is the walk root relative to the repository root, or "" when
+	// they are the same.
+	prefix string
+}
+
+// newIgnoreMatcher returns a matcher for a walk of root, holding the rules
+// that apply from above root: the user's core.excludesFile, the repository's
+// info/exclude file and the .gitignore files from the repository root down
+// to root. Outside a repository it starts out empty.
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return m
+	}
+	// The walk loads the .gitignore file of a root directory itself.
+	isDir := true
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir, isDir = filepath.Dir(dir), false
+	}
+	repo, gitDir := findRepository(dir)
+	if repo == "" {
+		return m
+	}
+	if rel, err := filepath.Rel(repo, dir); err == nil && rel != "." {
+		m.prefix = filepath.ToSlash(rel)
+	}
+
+	m.load(globalExcludesFile(repo), "")
+	m.load(filepath.Join(gitDir, "info", "exclude"), "")
+	dirs := []string{""}
+	if m.prefix != "" {
+		parts := strings.Split(m.prefix, "/")
+		for i := range parts {
+			dirs = append(dirs, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	if isDir {
+		dirs = dirs[:len(dirs)-1]
+	}
+	for _, d := range dirs {
+		m.load(filepath.Join(repo, filepath.FromSlash(d), ".gitignore"), d)
+	}
+	return m
+}
+
+// findRepository returns the root of the git repository containing dir and
+// its git directory, or empty strings if dir is not in a repository.
+func findRepository(dir string) (string, string) {
+	for {
+		gitPath := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitPath); err == nil {
+			if info.IsDir() {
+				return dir, gitPath
+			}
+			// Worktrees and submodules have a .git file pointing to the git
+			// directory.
+			data, err := os.ReadFile(gitPath)
+			if err != nil {
+				return dir, gitPath
+			}
+			gitDir := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return dir, gitDir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// globalExcludesFile returns the path of the user's global ignore file, as
+// set by core.excludesFile or at its default location.
+func globalExcludesFile(repo string) string {
+	cmd := exec.Command("git", "config", "--path", "--get", "core.excludesFile")
+	cmd.Dir = repo
+	if out, err := cmd.Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+		return strings.TrimSpace(string(out))
+	}
+	if config := os.Getenv("XDG_CONFIG_HOME"); config != "" {
+		return filepath.Join(config, "git", "ignore")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "git", "ignore")
+}
+
+// repoPath converts a path relative to the walk root into one relative to
+// the repository root.
+func (m *ignoreMatcher) repoPath(rel string) string {
+	if rel == "." {
+		rel = ""
+	}
+	switch {
+	case m.prefix == "":
+		return rel
+	case rel == "":
+		return m.prefix
+	}
+	return m.prefix + "/" + rel
+}
+
+// load adds the rules in the ignore file at path, which apply to paths under
+// base. A missing file is not an error.
+func (m *ignoreMatcher) load(path, base string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// A slash anywhere but the end anchors the pattern to base.
+		anchored := strings.Contains(line, "/")
+		re, err := compileGlob(strings.TrimPrefix(line, "/"), anchored)
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// ignored reports whether rel, a slash-separated path relative to the walk
+// root, is ignored.
+func (m *ignoreMatcher) ignored(rel string, isDir bool) bool {
+	rel = m.repoPath(rel)
+	ignored := false
+	for _, rule := range m.rules {
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = rel[len(rule.base)+1:]
+		}
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.re.MatchString(sub) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// compileGlob converts a gitignore-style glob into a regular expression
+// matched against slash-separated relative paths. "*" and "?" do not match
+// "/", while "**" matches any number of directories. Unanchored patterns
+// match at any depth.
+func compileGlob(pattern string, anchored bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored && !strings.HasPrefix(pattern, "**/") {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// walkFiles calls fn for every regular file under root that is not ignored by
+// git's ignore files, including those of the directories above root, in
+// lexical order. The .git directory is always skipped.
+// rel is the slash-separated path relative to root.
+func walkFiles(root string, fn func(path, rel string) error) error {
+	if root == "" {
+		root = "."
+	}
+	matcher := newIgnoreMatcher(root)
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// Unreadable entries are skipped rather than aborting the walk.
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." {
+				if d.Name() == ".git" || matcher.ignored(rel, true) {
+					return filepath.SkipDir
+				}
+			}
+			matcher.load(filepath.Join(path, ".gitignore"), matcher.repoPath(rel))
+			return nil
+		}
+		if rel == "." {
+			// root is a single file.
+			rel = d.Name()
+		}
+		if !d.Type().IsRegular() || matcher.ignored(rel, false) {
+			return nil
+		}
+		return fn(path, rel)
+	})
+}
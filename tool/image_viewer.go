@@ -0,0 +1,93 @@
+package tool
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const maxImageBytes = 20 * 1024 * 1024
+
+// ImageProvider is implemented by tools whose results include images. The
+// agent shows the pending images to the model after the tool calls of a turn
+// have been executed, since tool messages can only carry text.
+type ImageProvider interface {
+	TakeImages() []string
+}
+
+// ImageDataURL reads a PNG, JPEG, GIF or WebP file and returns it as a base64
+// data URL suitable for an image content part.
+func ImageDataURL(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxImageBytes {
+		return "", fmt.Errorf("%s is too large (%d bytes, limit %d)", path, len(data), maxImageBytes)
+	}
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+	default:
+		return "", fmt.Errorf("%s is not a supported image (detected %s)", path, contentType)
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ViewImageTool lets the agent look at an image file, such as a screenshot of
+// a page it rendered.
+type ViewImageTool struct {
+	mu      sync.Mutex
+	pending []string
+}
+
+func (t *ViewImageTool) Name() string {
+	return "view_image"
+}
+
+func (t *ViewImageTool) Description() string {
+	return "A tool for viewing an image file (PNG, JPEG, GIF or WebP). The image is shown to you after the tool call. Input should be a JSON object with a 'path' key."
+}
+
+func (t *ViewImageTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"path": {
+				"type": "string",
+				"description": "The path of the image file to view."
+			}
+		},
+		"required": ["path"]
+	}`)
+}
+
+func (t *ViewImageTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for view_image tool: %w", err)
+	}
+
+	dataURL, err := ImageDataURL(params.Path)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.pending = append(t.pending, dataURL)
+	t.mu.Unlock()
+	return fmt.Sprintf("Loaded image %s. It is attached to the next message.", params.Path), nil
+}
+
+// TakeImages returns the images loaded since the last call and forgets them.
+func (t *ViewImageTool) TakeImages() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	images := t.pending
+	t.pending = nil
+	return images
+}
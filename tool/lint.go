@@ -0,0 +1,445 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLintTimeout = 5 * time.Minute
+	defaultLintLimit   = 100
+)
+
+var (
+	// lintPositionPattern matches "file:line:col" and "file:line".
+	lintPositionPattern = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?$`)
+	// lintTextPattern matches diagnostics printed as text, such as type
+	// errors reported by go vet.
+	lintTextPattern = regexp.MustCompile(`^(?:vet: )?(.+?\.go):(\d+):(?:(\d+):)? (.+)$`)
+)
+
+// LintTool runs go vet, and staticcheck and golangci-lint when they are
+// installed, and reports their diagnostics in one deduplicated list.
+type LintTool struct {
+	// Sandbox confines the linters when set.
+	Sandbox *SandboxProfile
+}
+
+// Diagnostic is a problem reported by a linter.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Analyzer string `json:"analyzer"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	position := fmt.Sprintf("%s:%d", d.File, d.Line)
+	if d.Column > 0 {
+		position += fmt.Sprintf(":%d", d.Column)
+	}
+	return fmt.Sprintf("%s: [%s] %s", position, d.Analyzer, d.Message)
+}
+
+// linter runs one analysis tool over packages in dir and returns its
+// diagnostics.
+type linter struct {
+	name string
+	run  func(ctx context.Context, sandbox *SandboxProfile, dir string, packages []string) ([]Diagnostic, error)
+}
+
+var linters = []linter{
+	{"go vet", runGoVet},
+	{"staticcheck", runStaticcheck},
+	{"golangci-lint", runGolangciLint},
+}
+
+func (t *LintTool) Name() string {
+	return "lint"
+}
+
+func (t *LintTool) Description() string {
+	return "A tool for statically checking Go code. Runs go vet, plus staticcheck and golangci-lint when installed, and returns deduplicated diagnostics as 'file:line:col: [analyzer] message'. Use it to verify changes before declaring a task done."
+}
+
+func (t *LintTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"paths": {
+				"type": "array",
+				"items": {"type": "string"},
+				"description": "Packages (e.g. './...' or './agent') or .go files to check. Diagnostics for files are limited to those files. Defaults to ['./...']."
+			},
+			"dir": {
+				"type": "string",
+				"description": "The module directory to run in. Defaults to the current directory."
+			},
+			"linters": {
+				"type": "array",
+				"items": {"type": "string", "enum": ["go vet", "staticcheck", "golangci-lint"]},
+				"description": "The linters to run. Defaults to all installed linters."
+			},
+			"limit": {
+				"type": "integer",
+				"description": "The maximum number of diagnostics to return. Defaults to 100."
+			}
+		}
+	}`)
+}
+
+func (t *LintTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Paths   []string `json:"paths"`
+		Dir     string   `json:"dir"`
+		Linters []string `json:"linters"`
+		Limit   int      `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for lint tool: %w", err)
+	}
+	if params.Limit < 1 {
+		params.Limit = defaultLintLimit
+	}
+	packages, files := lintTargets(params.Paths)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultLintTimeout)
+	defer cancel()
+
+	var diagnostics []Diagnostic
+	var ran, skipped, failed []string
+	for _, l := range linters {
+		if len(params.Linters) > 0 && !slices.Contains(params.Linters, l.name) {
+			continue
+		}
+		binary := strings.Fields(l.name)[0]
+		if _, err := exec.LookPath(binary); err != nil {
+			skipped = append(skipped, l.name)
+			continue
+		}
+		found, err := l.run(ctx, t.Sandbox, params.Dir, packages)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", l.name, err))
+			continue
+		}
+		ran = append(ran, l.name)
+		diagnostics = append(diagnostics, found...)
+	}
+	if len(ran) == 0 && len(failed) > 0 {
+		return "", fmt.Errorf("no linter could run:\n%s", strings.Join(failed, "\n"))
+	}
+
+	diagnostics = normalizeDiagnostics(diagnostics, params.Dir, files)
+
+	var b strings.Builder
+	if len(diagnostics) == 0 {
+		fmt.Fprintf(&b, "No problems found by %s.", strings.Join(ran, ", "))
+	} else {
+		fmt.Fprintf(&b, "%d problem(s) found by %s:\n", len(diagnostics), strings.Join(ran, ", "))
+		for i, d := range diagnostics {
+			if i == params.Limit {
+				fmt.Fprintf(&b, "... and %d more; fix these first or raise the limit\n", len(diagnostics)-i)
+				break
+			}
+			b.WriteString(d.String() + "\n")
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\nNot installed: %s", strings.Join(skipped, ", "))
+	}
+	for _, f := range failed {
+		fmt.Fprintf(&b, "\nFailed to run %s", f)
+	}
+	return truncateOutput(strings.TrimRight(b.String(), "\n"), "lint"), nil
+}
+
+// lintTargets splits paths into the packages to check and the files to
+// report on. Files are checked by checking their package.
+func lintTargets(paths []string) ([]string, []string) {
+	if len(paths) == 0 {
+		return []string{"./..."}, nil
+	}
+	var packages, files []string
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		pkg := path
+		if strings.HasSuffix(path, ".go") {
+			files = append(files, path)
+			pkg = filepath.Dir(path)
+		}
+		first, _, _ := strings.Cut(pkg, "/")
+		if !strings.HasPrefix(pkg, ".") && !filepath.IsAbs(pkg) && !strings.Contains(first, ".") {
+			// A relative directory such as "agent" would be taken for a
+			// standard library import path.
+			pkg = "./" + pkg
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			packages = append(packages, pkg)
+		}
+	}
+	return packages, files
+}
+
+// normalizeDiagnostics makes file names relative to dir, keeps only the
+// diagnostics for files when any are given, removes duplicates reported by
+// several linters and sorts the result by position.
+func normalizeDiagnostics(diagnostics []Diagnostic, dir string, files []string) []Diagnostic {
+	base, err := filepath.Abs(dir)
+	if err != nil {
+		base = dir
+	}
+	wanted := make(map[string]bool)
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(base, file)
+		}
+		wanted[filepath.Clean(file)] = true
+	}
+
+	seen := make(map[string]bool)
+	var result []Diagnostic
+	for _, d := range diagnostics {
+		abs := d.File
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(base, abs)
+		}
+		abs = filepath.Clean(abs)
+		if len(wanted) > 0 && !wanted[abs] {
+			continue
+		}
+		if rel, err := filepath.Rel(base, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			d.File = rel
+		} else {
+			d.File = abs
+		}
+
+		key := fmt.Sprintf("%s:%d:%d:%s", d.File, d.Line, d.Column, strings.ToLower(strings.TrimSpace(d.Message)))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, d)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return result
+}
+
+// runLinterCommand runs a linter and returns its stdout and stderr. Linters
+// exit non-zero when they find problems, so exit errors are not failures.
+func runLinterCommand(ctx context.Context, sandbox *SandboxProfile, dir, name string, args ...string) (string, string, error) {
+	cmd, err := sandboxedCommand(ctx, sandbox, dir, name, args...)
+	if err != nil {
+		return "", "", err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", "", fmt.Errorf("timed out after %s", defaultLintTimeout)
+	}
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return "", "", err
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// runGoVet runs go vet -json. Its output interleaves "# package" headers,
+// JSON objects mapping packages to analyzers to diagnostics, and type errors
+// printed as text. Depending on the Go version, the JSON is written to stdout
+// or stderr.
+func runGoVet(ctx context.Context, sandbox *SandboxProfile, dir string, packages []string) ([]Diagnostic, error) {
+	stdout, stderr, err := runLinterCommand(ctx, sandbox, dir, "go", append([]string{"vet", "-json"}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []Diagnostic
+	var unparsed []string
+	lines := strings.Split(stdout+"\n"+stderr, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "{"):
+			end := i
+			for end < len(lines) && !strings.HasPrefix(lines[end], "}") {
+				end++
+			}
+			if line == "{}" {
+				end = i
+			}
+			found, err := parseVetJSON(strings.Join(lines[i:min(end+1, len(lines))], "\n"))
+			if err != nil {
+				return nil, fmt.Errorf("unexpected go vet output: %w", err)
+			}
+			diagnostics = append(diagnostics, found...)
+			i = end
+		case strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "":
+		default:
+			if d, ok := parseTextDiagnostic(line, "typecheck"); ok {
+				diagnostics = append(diagnostics, d)
+			} else {
+				unparsed = append(unparsed, line)
+			}
+		}
+	}
+	if len(diagnostics) == 0 && len(unparsed) > 0 {
+		// Package loading errors, such as a missing go.mod.
+		return nil, fmt.Errorf("%s", strings.Join(unparsed, "\n"))
+	}
+	return diagnostics, nil
+}
+
+func parseVetJSON(data string) ([]Diagnostic, error) {
+	var packages map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &packages); err != nil {
+		return nil, err
+	}
+	var diagnostics []Diagnostic
+	for _, analyzers := range packages {
+		for analyzer, raw := range analyzers {
+			var found []struct {
+				Posn    string `json:"posn"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(raw, &found); err != nil {
+				// Analyzers that failed report {"error": "..."}.
+				var failure struct {
+					Error string `json:"error"`
+				}
+				if json.Unmarshal(raw, &failure) == nil && failure.Error != "" {
+					if d, ok := parseTextDiagnostic(failure.Error, analyzer); ok {
+						diagnostics = append(diagnostics, d)
+					}
+				}
+				continue
+			}
+			for _, f := range found {
+				d := Diagnostic{Analyzer: analyzer, Message: f.Message}
+				if m := lintPositionPattern.FindStringSubmatch(f.Posn); m != nil {
+					d.File = m[1]
+					d.Line, _ = strconv.Atoi(m[2])
+					d.Column, _ = strconv.Atoi(m[3])
+				} else {
+					d.File = f.Posn
+				}
+				diagnostics = append(diagnostics, d)
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+func parseTextDiagnostic(line, analyzer string) (Diagnostic, bool) {
+	m := lintTextPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Diagnostic{}, false
+	}
+	d := Diagnostic{File: m[1], Analyzer: analyzer, Message: m[4]}
+	d.Line, _ = strconv.Atoi(m[2])
+	d.Column, _ = strconv.Atoi(m[3])
+	return d, true
+}
+
+// runStaticcheck runs staticcheck, which prints one JSON object per problem.
+func runStaticcheck(ctx context.Context, sandbox *SandboxProfile, dir string, packages []string) ([]Diagnostic, error) {
+	stdout, stderr, err := runLinterCommand(ctx, sandbox, dir, "staticcheck", append([]string{"-f", "json"}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+	var diagnostics []Diagnostic
+	decoder := json.NewDecoder(strings.NewReader(stdout))
+	for decoder.More() {
+		var problem struct {
+			Code     string `json:"code"`
+			Location struct {
+				File   string `json:"file"`
+				Line   int    `json:"line"`
+				Column int    `json:"column"`
+			} `json:"location"`
+			Message string `json:"message"`
+		}
+		if err := decoder.Decode(&problem); err != nil {
+			return nil, fmt.Errorf("unexpected staticcheck output: %w", err)
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     problem.Location.File,
+			Line:     problem.Location.Line,
+			Column:   problem.Location.Column,
+			Analyzer: "staticcheck/" + problem.Code,
+			Message:  problem.Message,
+		})
+	}
+	if len(diagnostics) == 0 && strings.TrimSpace(stderr) != "" {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(stderr))
+	}
+	return diagnostics, nil
+}
+
+// runGolangciLint runs golangci-lint with JSON output. The flag selecting
+// JSON output changed in version 2.
+func runGolangciLint(ctx context.Context, sandbox *SandboxProfile, dir string, packages []string) ([]Diagnostic, error) {
+	formatFlag := "--out-format=json"
+	if version, err := exec.CommandContext(ctx, "golangci-lint", "version").CombinedOutput(); err == nil &&
+		regexp.MustCompile(`version v?2\.`).Match(version) {
+		formatFlag = "--output.json.path=stdout"
+	}
+	stdout, stderr, err := runLinterCommand(ctx, sandbox, dir, "golangci-lint", append([]string{"run", formatFlag}, packages...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Version 2 may print a text summary after the JSON document.
+	var report struct {
+		Issues []struct {
+			FromLinter string `json:"FromLinter"`
+			Text       string `json:"Text"`
+			Pos        struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	if err := json.NewDecoder(strings.NewReader(stdout)).Decode(&report); err != nil {
+		if strings.TrimSpace(stderr) != "" {
+			return nil, fmt.Errorf("%s", strings.TrimSpace(stderr))
+		}
+		return nil, fmt.Errorf("unexpected golangci-lint output: %w", err)
+	}
+	var diagnostics []Diagnostic
+	for _, issue := range report.Issues {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:     issue.Pos.Filename,
+			Line:     issue.Pos.Line,
+			Column:   issue.Pos.Column,
+			Analyzer: "golangci-lint/" + issue.FromLinter,
+			Message:  issue.Text,
+		})
+	}
+	return diagnostics, nil
+}
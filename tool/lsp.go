@@ -0,0 +1,458 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sgoal/tide/lsp"
+)
+
+const (
+	// lspTimeout bounds a request, including starting the server and loading
+	// the workspace on first use.
+	lspTimeout = 60 * time.Second
+	// lspDiagnosticsWait is how long to wait for fresh diagnostics after a
+	// file changed.
+	lspDiagnosticsWait = 10 * time.Second
+	maxLSPResults      = 100
+)
+
+// LSPTool gives the agent code intelligence from language servers: go to
+// definition, find references, hover, workspace symbols, rename and
+// diagnostics.
+type LSPTool struct {
+	Manager *lsp.Manager
+	// Tracker records the files changed by a rename.
+	Tracker *ReadTracker
+}
+
+func (t *LSPTool) Name() string {
+	return "lsp"
+}
+
+func (t *LSPTool) Description() string {
+	return "A tool for code intelligence from language servers (gopls, typescript-language-server, pyright). Actions: 'definition', 'references' and 'hover' for the symbol at a position, 'symbols' to search workspace symbols by name, 'rename' to rename a symbol across the workspace, and 'diagnostics' to list compiler errors and warnings. Positions are a 1-based line plus either a 1-based column or the symbol's name on that line."
+}
+
+func (t *LSPTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["definition", "references", "hover", "symbols", "rename", "diagnostics"],
+				"description": "The operation to perform."
+			},
+			"path": {
+				"type": "string",
+				"description": "The file to query. Required for all actions except 'symbols' and 'diagnostics', which cover the whole workspace without it."
+			},
+			"line": {
+				"type": "integer",
+				"description": "The 1-based line of the symbol."
+			},
+			"column": {
+				"type": "integer",
+				"description": "The 1-based column of the symbol. Can be omitted when 'symbol' is given."
+			},
+			"symbol": {
+				"type": "string",
+				"description": "The name of the symbol on the given line; its first occurrence is used as the position."
+			},
+			"query": {
+				"type": "string",
+				"description": "The name to search for with 'symbols'."
+			},
+			"new_name": {
+				"type": "string",
+				"description": "The new name for 'rename'."
+			}
+		},
+		"required": ["action"]
+	}`)
+}
+
+func (t *LSPTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Action  string `json:"action"`
+		Path    string `json:"path"`
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Symbol  string `json:"symbol"`
+		Query   string `json:"query"`
+		NewName string `json:"new_name"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for lsp tool: %w", err)
+	}
+	if t.Manager == nil {
+		return "", fmt.Errorf("language servers are not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lspTimeout)
+	defer cancel()
+
+	switch params.Action {
+	case "symbols":
+		return t.symbols(ctx, params.Path, params.Query)
+	case "diagnostics":
+		return t.diagnostics(ctx, params.Path)
+	case "definition", "references", "hover", "rename":
+	default:
+		return "", fmt.Errorf("unknown action %q", params.Action)
+	}
+
+	if params.Path == "" || params.Line < 1 {
+		return "", fmt.Errorf("'path' and 'line' are required for the %s action", params.Action)
+	}
+	client, err := t.Manager.ClientFor(ctx, params.Path)
+	if err != nil {
+		return "", err
+	}
+	// Other tools may have edited open files since the last query; the
+	// server must see them before it answers or computes edits.
+	client.SyncOpen()
+	if _, err := client.Sync(params.Path); err != nil {
+		return "", err
+	}
+	pos, err := t.position(params.Path, params.Line, params.Column, params.Symbol)
+	if err != nil {
+		return "", err
+	}
+
+	switch params.Action {
+	case "definition":
+		locations, err := client.Definition(ctx, params.Path, pos)
+		if err != nil {
+			return "", err
+		}
+		if len(locations) == 0 {
+			return "No definition found.", nil
+		}
+		return t.formatLocations(locations), nil
+	case "references":
+		locations, err := client.References(ctx, params.Path, pos)
+		if err != nil {
+			return "", err
+		}
+		if len(locations) == 0 {
+			return "No references found.", nil
+		}
+		return fmt.Sprintf("%d reference(s):\n%s", len(locations), t.formatLocations(locations)), nil
+	case "hover":
+		text, err := client.Hover(ctx, params.Path, pos)
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return "No information for this position.", nil
+		}
+		return text, nil
+	default:
+		if params.NewName == "" {
+			return "", fmt.Errorf("'new_name' is required for the rename action")
+		}
+		edit, err := client.Rename(ctx, params.Path, pos, params.NewName)
+		if err != nil {
+			return "", err
+		}
+		return t.applyRename(client, edit)
+	}
+}
+
+// position converts the agent's one-based line and column, or the symbol
+// name on the line, into an LSP position.
+func (t *LSPTool) position(path string, line, column int, symbol string) (lsp.Position, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lsp.Position{}, err
+	}
+	content := string(data)
+	lines := strings.Split(content, "\n")
+	if line > len(lines) {
+		return lsp.Position{}, fmt.Errorf("%s has only %d lines", path, len(lines))
+	}
+	if symbol != "" {
+		i := strings.Index(lines[line-1], symbol)
+		if i < 0 {
+			return lsp.Position{}, fmt.Errorf("%q does not occur on line %d of %s: %s", symbol, line, path, strings.TrimSpace(lines[line-1]))
+		}
+		column = len([]rune(lines[line-1][:i])) + 1
+	}
+	if column < 1 {
+		return lsp.Position{}, fmt.Errorf("'column' or 'symbol' is required")
+	}
+	return lsp.PositionAt(content, line, column), nil
+}
+
+// formatLocations renders locations as "path:line:column: source line".
+func (t *LSPTool) formatLocations(locations []lsp.Location) string {
+	contents := make(map[string]string)
+	var b strings.Builder
+	for i, location := range locations {
+		if i == maxLSPResults {
+			fmt.Fprintf(&b, "... and %d more\n", len(locations)-i)
+			break
+		}
+		path, err := lsp.URIToPath(location.URI)
+		if err != nil {
+			fmt.Fprintf(&b, "%s\n", location.URI)
+			continue
+		}
+		content, ok := contents[path]
+		if !ok {
+			data, _ := os.ReadFile(path)
+			content = string(data)
+			contents[path] = content
+		}
+		line, column := lsp.LineColumn(content, location.Range.Start)
+		text := ""
+		if lines := strings.Split(content, "\n"); line-1 < len(lines) {
+			text = strings.TrimSpace(lines[line-1])
+		}
+		fmt.Fprintf(&b, "%s:%d:%d: %s\n", t.relative(path), line, column, text)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (t *LSPTool) symbols(ctx context.Context, path, query string) (string, error) {
+	if query == "" {
+		return "", fmt.Errorf("'query' is required for the symbols action")
+	}
+	var clients []*lsp.Client
+	if path != "" {
+		client, err := t.Manager.ClientFor(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		clients = []*lsp.Client{client}
+	} else {
+		var err error
+		if clients, err = t.Manager.WorkspaceClients(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	var symbols []lsp.SymbolInformation
+	for _, client := range clients {
+		found, err := client.WorkspaceSymbols(ctx, query)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", client.Name(), err)
+		}
+		symbols = append(symbols, found...)
+	}
+	if len(symbols) == 0 {
+		return fmt.Sprintf("No symbols match %q.", query), nil
+	}
+	// Servers also search dependencies and the standard library; list the
+	// workspace's own symbols first.
+	root := t.Manager.Root() + string(filepath.Separator)
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return strings.HasPrefix(symbolPath(symbols[i]), root) && !strings.HasPrefix(symbolPath(symbols[j]), root)
+	})
+
+	var b strings.Builder
+	contents := make(map[string]string)
+	for i, symbol := range symbols {
+		if i == maxLSPResults {
+			fmt.Fprintf(&b, "... and %d more; use a more specific query\n", len(symbols)-i)
+			break
+		}
+		name := symbol.Name
+		if symbol.ContainerName != "" {
+			name = symbol.ContainerName + "." + name
+		}
+		location := symbol.Location.URI
+		if path, err := lsp.URIToPath(symbol.Location.URI); err == nil {
+			content, ok := contents[path]
+			if !ok {
+				data, _ := os.ReadFile(path)
+				content = string(data)
+				contents[path] = content
+			}
+			line, column := lsp.LineColumn(content, symbol.Location.Range.Start)
+			location = fmt.Sprintf("%s:%d:%d", t.relative(path), line, column)
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", location, symbol.KindName(), name)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+func (t *LSPTool) diagnostics(ctx context.Context, path string) (string, error) {
+	if path != "" {
+		client, err := t.Manager.ClientFor(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		since := time.Now()
+		changed, err := client.Sync(path)
+		if err != nil {
+			return "", err
+		}
+		wait := time.Duration(0)
+		if changed {
+			wait = lspDiagnosticsWait
+		}
+		diagnostics := client.FileDiagnostics(ctx, path, since, wait)
+		if len(diagnostics) == 0 {
+			return fmt.Sprintf("No problems reported for %s.", path), nil
+		}
+		return FormatDiagnostics([]lsp.FileDiagnostics{{Path: path, Diagnostics: diagnostics}}, t.Manager.Root()), nil
+	}
+
+	clients, err := t.Manager.WorkspaceClients(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, client := range clients {
+		client.SyncOpen()
+	}
+	all := t.Manager.Diagnostics()
+	if len(all) == 0 {
+		return "No problems reported. Only files the language servers have analyzed are covered; pass 'path' to check a specific file.", nil
+	}
+	return FormatDiagnostics(all, t.Manager.Root()), nil
+}
+
+// applyRename writes the edits of a rename to disk and tells the server
+// about the new content of the changed files.
+func (t *LSPTool) applyRename(client *lsp.Client, edit *lsp.WorkspaceEdit) (string, error) {
+	fileEdits, err := edit.FileEdits()
+	if err != nil {
+		return "", err
+	}
+	if len(fileEdits) == 0 {
+		return "The rename changed nothing.", nil
+	}
+
+	// Compute every new file content first, so that a bad edit changes nothing.
+	original := make(map[string][]byte)
+	updated := make(map[string]string)
+	for path, edits := range fileEdits {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		// The edits are offsets into the content the server saw; applied to
+		// a file that changed since, they would corrupt it.
+		if synced, ok := client.Synced(path); ok && synced != string(data) {
+			return "", fmt.Errorf("%s changed while the rename was computed; no files were changed, run the rename again", t.relative(path))
+		}
+		content, err := applyTextEdits(string(data), edits)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		original[path] = data
+		updated[path] = content
+	}
+
+	var paths []string
+	for path := range updated {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	// If a file cannot be written, the files written before it are restored.
+	for i, path := range paths {
+		if _, err := writeFileAtomic(path, []byte(updated[path])); err != nil {
+			var failed []string
+			for _, written := range paths[:i] {
+				if _, err := writeFileAtomic(written, original[written]); err != nil {
+					failed = append(failed, t.relative(written))
+				}
+			}
+			if len(failed) > 0 {
+				return "", fmt.Errorf("failed to write %s: %w; these files could not be restored and keep the rename: %s", path, err, strings.Join(failed, ", "))
+			}
+			return "", fmt.Errorf("failed to write %s: %w; no files were changed", path, err)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Renamed in %d file(s):\n", len(paths))
+	for _, path := range paths {
+		t.Tracker.Record(path)
+		client.Sync(path)
+		fmt.Fprintf(&b, "%s (%d edit(s))\n", t.relative(path), len(fileEdits[path]))
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// applyTextEdits applies non-overlapping LSP text edits to content.
+func applyTextEdits(content string, edits []lsp.TextEdit) (string, error) {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, edit := range edits {
+		spans = append(spans, span{lsp.Offset(content, edit.Range.Start), lsp.Offset(content, edit.Range.End), edit.NewText})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.start < last || s.end < s.start {
+			return "", fmt.Errorf("overlapping edits")
+		}
+		b.WriteString(content[last:s.start])
+		b.WriteString(s.text)
+		last = s.end
+	}
+	b.WriteString(content[last:])
+	return b.String(), nil
+}
+
+func (t *LSPTool) relative(path string) string {
+	if rel, err := filepath.Rel(t.Manager.Root(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// FormatDiagnostics renders diagnostics as "path:line:column: severity:
+// message (source)", with paths relative to root.
+func FormatDiagnostics(files []lsp.FileDiagnostics, root string) string {
+	var b strings.Builder
+	count := 0
+	for _, file := range files {
+		data, _ := os.ReadFile(file.Path)
+		path := file.Path
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		for _, d := range file.Diagnostics {
+			if count == maxLSPResults {
+				b.WriteString("... more problems omitted\n")
+				return strings.TrimRight(b.String(), "\n")
+			}
+			count++
+			line, column := lsp.LineColumn(string(data), d.Range.Start)
+			fmt.Fprintf(&b, "%s:%d:%d: %s: %s", path, line, column, d.SeverityName(), d.Message)
+			if d.Source != "" {
+				fmt.Fprintf(&b, " (%s)", d.Source)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Close shuts down the language servers.
+func (t *LSPTool) Close() error {
+	if t.Manager == nil {
+		return nil
+	}
+	return t.Manager.Close()
+}
+
+func symbolPath(symbol lsp.SymbolInformation) string {
+	path, _ := lsp.URIToPath(symbol.Location.URI)
+	return path
+}
@@ -0,0 +1,85 @@
+package tool
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sgoal/tide/redact"
+)
+
+const (
+	// maxOutputBytes is the most command output returned to the model.
+	maxOutputBytes = 30000
+	// outputEdgeBytes is how much of the start and the end of an oversized
+	// output is kept.
+	outputEdgeBytes = 12000
+)
+
+var (
+	outputRedactorMu sync.Mutex
+	outputRedactor   *redact.Redactor
+)
+
+// SetOutputRedactor sets the redactor applied to output saved to disk. Until
+// it is called, the default redactor is used.
+func SetOutputRedactor(r *redact.Redactor) {
+	outputRedactorMu.Lock()
+	defer outputRedactorMu.Unlock()
+	outputRedactor = r
+}
+
+func savedOutputRedactor() *redact.Redactor {
+	outputRedactorMu.Lock()
+	defer outputRedactorMu.Unlock()
+	if outputRedactor == nil {
+		// Default returns a usable redactor even when it reports an error.
+		outputRedactor, _ = redact.Default()
+	}
+	return outputRedactor
+}
+
+// truncateOutput caps output at maxOutputBytes, keeping its head and tail. The
+// full output is saved to a file whose path is included in the result, so the
+// agent can page through it.
+func truncateOutput(output, name string) string {
+	if len(output) <= maxOutputBytes {
+		return output
+	}
+
+	saved := "it could not be saved"
+	if path, err := saveOutput(output, name); err == nil {
+		saved = "the full output was saved to " + path
+	}
+	// Cut at rune boundaries so that no character is split.
+	head, tail := outputEdgeBytes, len(output)-outputEdgeBytes
+	for head > 0 && !utf8.RuneStart(output[head]) {
+		head--
+	}
+	for tail < len(output) && !utf8.RuneStart(output[tail]) {
+		tail++
+	}
+	omitted := tail - head
+	return fmt.Sprintf("%s\n\n... [%d bytes omitted; %s] ...\n\n%s",
+		output[:head], omitted, saved, output[tail:])
+}
+
+// saveOutput writes output, with secrets redacted, to a new file in the
+// user's private output directory.
+func saveOutput(output, name string) (string, error) {
+	dir, err := privateDir("output")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%s-%s-*.log", name, time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.WriteString(savedOutputRedactor().Redact(output)); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,168 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	pluginManifestFile   = "plugin.json"
+	defaultPluginTimeout = 60 * time.Second
+)
+
+// pluginNamePattern matches the tool names the model provider accepts.
+var pluginNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// PluginManifest describes an external executable tool. It is read from a
+// plugin.json file inside the plugin's directory.
+type PluginManifest struct {
+	Name           string          `json:"name"`
+	Description    string          `json:"description"`
+	Parameters     json.RawMessage `json:"parameters"`
+	Command        string          `json:"command"`
+	TimeoutSeconds int             `json:"timeout_seconds,omitempty"`
+}
+
+// PluginTool is a tool backed by an external executable. The tool-call
+// arguments are written to the executable's stdin and whatever it prints to
+// stdout is returned as the observation.
+type PluginTool struct {
+	manifest PluginManifest
+	dir      string
+	command  string
+}
+
+// DefaultPluginDir returns the directory plugins are discovered from. It can be
+// overridden with the TIDE_PLUGIN_DIR environment variable.
+func DefaultPluginDir() string {
+	if dir := os.Getenv("TIDE_PLUGIN_DIR"); dir != "" {
+		return dir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "plugins")
+}
+
+// LoadPlugins loads every plugin found in dir. Each plugin lives in its own
+// subdirectory containing a plugin.json manifest and the executable it names.
+// Plugins that fail to load are skipped and reported in the returned error.
+func LoadPlugins(dir string) ([]*PluginTool, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []*PluginTool
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		plugin, err := loadPlugin(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", entry.Name(), err))
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, errors.Join(errs...)
+}
+
+func loadPlugin(dir string) (*PluginTool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, pluginManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest PluginManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("manifest is missing 'name'")
+	}
+	if !pluginNamePattern.MatchString(manifest.Name) {
+		return nil, fmt.Errorf("invalid name %q: use up to 64 letters, digits, '_' or '-'", manifest.Name)
+	}
+	if manifest.Command == "" {
+		return nil, fmt.Errorf("manifest is missing 'command'")
+	}
+	if len(manifest.Parameters) == 0 {
+		manifest.Parameters = json.RawMessage(`{"type": "object", "properties": {}}`)
+	} else if !json.Valid(manifest.Parameters) {
+		return nil, fmt.Errorf("manifest 'parameters' is not valid JSON")
+	}
+
+	command := manifest.Command
+	if !filepath.IsAbs(command) {
+		command = filepath.Join(dir, command)
+	}
+	info, err := os.Stat(command)
+	if err != nil {
+		return nil, fmt.Errorf("command not found: %w", err)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return nil, fmt.Errorf("command %s is not executable", command)
+	}
+
+	return &PluginTool{manifest: manifest, dir: dir, command: command}, nil
+}
+
+func (t *PluginTool) Name() string {
+	return t.manifest.Name
+}
+
+func (t *PluginTool) Description() string {
+	return t.manifest.Description
+}
+
+// Parameters returns the JSON schema declared in the plugin manifest.
+func (t *PluginTool) Parameters() json.RawMessage {
+	return t.manifest.Parameters
+}
+
+// Execute runs the plugin executable with args on stdin and returns its stdout.
+func (t *PluginTool) Execute(args json.RawMessage) (string, error) {
+	timeout := defaultPluginTimeout
+	if t.manifest.TimeoutSeconds > 0 {
+		timeout = time.Duration(t.manifest.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command)
+	cmd.Stdin = bytes.NewReader(args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "TIDE_PLUGIN_ROOT="+t.dir)
+	// Children the plugin starts are killed with it, and do not keep Run
+	// waiting by holding the output pipes open.
+	KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return stdout.String(), fmt.Errorf("plugin %s timed out after %s", t.manifest.Name, timeout)
+	}
+	if err != nil {
+		return stdout.String(), fmt.Errorf("plugin %s failed: %v\n%s", t.manifest.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,340 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxProcessOutputBytes is how much output is kept per background process.
+	maxProcessOutputBytes = 4 * 1024 * 1024
+	// processStartWait is how long start waits to report early output or a
+	// command that fails immediately.
+	processStartWait = time.Second
+	// processStopGrace is how long stop waits after SIGTERM before SIGKILL.
+	processStopGrace = 5 * time.Second
+)
+
+// ProcessManagerTool starts long-running commands, such as dev servers, in the
+// background and lets the agent read their output, signal and stop them.
+type ProcessManagerTool struct {
+	// Sandbox confines the processes when set.
+	Sandbox *SandboxProfile
+
+	mu        sync.Mutex
+	nextID    int
+	processes map[int]*backgroundProcess
+}
+
+type backgroundProcess struct {
+	id      int
+	command string
+	cmd     *exec.Cmd
+	started time.Time
+	output  *outputBuffer
+	// readOffset is how much output the agent has already read.
+	readOffset int
+	done       chan struct{}
+	exitErr    error
+}
+
+// outputBuffer keeps the most recent output of a process. Offsets are
+// absolute, counting the bytes dropped from the front.
+type outputBuffer struct {
+	mu      sync.Mutex
+	data    []byte
+	dropped int
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - maxProcessOutputBytes; over > 0 {
+		b.data = b.data[over:]
+		b.dropped += over
+	}
+	return len(p), nil
+}
+
+// since returns the output written after offset, how many bytes of it were
+// lost to the size cap, and the offset of the end of the output.
+func (b *outputBuffer) since(offset int) (string, int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	lost := 0
+	if offset < b.dropped {
+		lost = b.dropped - offset
+		offset = b.dropped
+	}
+	return string(b.data[offset-b.dropped:]), lost, b.dropped + len(b.data)
+}
+
+func (t *ProcessManagerTool) Name() string {
+	return "process"
+}
+
+func (t *ProcessManagerTool) Description() string {
+	return "A tool for managing long-running background processes such as dev servers. Actions: 'start' a command, 'list' processes, read new 'output' of a process, send a 'signal' to it, or 'stop' it. Background processes are stopped when the session ends."
+}
+
+func (t *ProcessManagerTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"action": {
+				"type": "string",
+				"enum": ["start", "list", "output", "signal", "stop"],
+				"description": "The operation to perform."
+			},
+			"command": {
+				"type": "string",
+				"description": "The shell command to start. Required for 'start'."
+			},
+			"cwd": {
+				"type": "string",
+				"description": "The working directory for 'start'. Defaults to the current directory."
+			},
+			"env": {
+				"type": "object",
+				"additionalProperties": {"type": "string"},
+				"description": "Extra environment variables for 'start'."
+			},
+			"id": {
+				"type": "integer",
+				"description": "The process id returned by 'start'. Required for 'output', 'signal' and 'stop'."
+			},
+			"signal": {
+				"type": "string",
+				"description": "The signal to send for 'signal', e.g. SIGINT, SIGTERM, SIGHUP or SIGKILL."
+			}
+		},
+		"required": ["action"]
+	}`)
+}
+
+func (t *ProcessManagerTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		Action  string            `json:"action"`
+		Command string            `json:"command"`
+		Cwd     string            `json:"cwd"`
+		Env     map[string]string `json:"env"`
+		ID      int               `json:"id"`
+		Signal  string            `json:"signal"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for process tool: %w", err)
+	}
+
+	switch params.Action {
+	case "start":
+		return t.start(params.Command, params.Cwd, params.Env)
+	case "list":
+		return t.list(), nil
+	}
+
+	p, err := t.lookup(params.ID)
+	if err != nil {
+		return "", err
+	}
+	switch params.Action {
+	case "output":
+		return t.readOutput(p), nil
+	case "signal":
+		if params.Signal == "" {
+			return "", fmt.Errorf("'signal' is required for the signal action")
+		}
+		if p.exited() {
+			return "", fmt.Errorf("process %d has already exited", p.id)
+		}
+		if err := signalProcessGroup(p.cmd, params.Signal); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Sent %s to process %d.", params.Signal, p.id), nil
+	case "stop":
+		p.stop()
+		return fmt.Sprintf("Stopped process %d (%s).\n%s", p.id, p.status(), t.readOutput(p)), nil
+	default:
+		return "", fmt.Errorf("unknown action %q", params.Action)
+	}
+}
+
+func (t *ProcessManagerTool) start(command, cwd string, env map[string]string) (string, error) {
+	if command == "" {
+		return "", fmt.Errorf("'command' is required for the start action")
+	}
+	for key := range env {
+		if !envNamePattern.MatchString(key) {
+			return "", fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+
+	var cmd *exec.Cmd
+	if t.Sandbox != nil {
+		var err error
+		if cmd, err = t.Sandbox.Command(context.Background(), command, cwd); err != nil {
+			return "", fmt.Errorf("failed to set up sandbox: %w", err)
+		}
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+		cmd.Dir = cwd
+	}
+	if len(env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	output := &outputBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	setProcessGroup(cmd)
+	// Children left holding the output pipe must not keep Wait from
+	// returning once the process exits.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start process: %w", err)
+	}
+
+	t.mu.Lock()
+	if t.processes == nil {
+		t.processes = make(map[int]*backgroundProcess)
+	}
+	t.nextID++
+	p := &backgroundProcess{
+		id:      t.nextID,
+		command: command,
+		cmd:     cmd,
+		started: time.Now(),
+		output:  output,
+		done:    make(chan struct{}),
+	}
+	t.processes[p.id] = p
+	t.mu.Unlock()
+
+	go func() {
+		p.exitErr = cmd.Wait()
+		close(p.done)
+	}()
+
+	select {
+	case <-p.done:
+	case <-time.After(processStartWait):
+	}
+	return fmt.Sprintf("Started process %d (pid %d): %s\n%s", p.id, cmd.Process.Pid, p.status(), t.readOutput(p)), nil
+}
+
+func (t *ProcessManagerTool) lookup(id int) (*backgroundProcess, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.processes[id]
+	if !ok {
+		return nil, fmt.Errorf("no background process with id %d", id)
+	}
+	return p, nil
+}
+
+func (t *ProcessManagerTool) list() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.processes) == 0 {
+		return "No background processes."
+	}
+
+	ids := make([]int, 0, len(t.processes))
+	for id := range t.processes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var b strings.Builder
+	for _, id := range ids {
+		p := t.processes[id]
+		fmt.Fprintf(&b, "%d\tpid %d\t%s\tstarted %s ago\t%s\n",
+			p.id, p.cmd.Process.Pid, p.status(), time.Since(p.started).Round(time.Second), p.command)
+	}
+	return b.String()
+}
+
+// readOutput returns the output produced since the last read.
+func (t *ProcessManagerTool) readOutput(p *backgroundProcess) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	output, lost, end := p.output.since(p.readOffset)
+	p.readOffset = end
+	if lost > 0 {
+		output = fmt.Sprintf("... [%d earlier bytes were discarded] ...\n%s", lost, output)
+	}
+	if output == "" {
+		return "(no new output)"
+	}
+	return truncateOutput(output, fmt.Sprintf("process-%d", p.id))
+}
+
+// Close stops every background process. It is called when the session ends.
+func (t *ProcessManagerTool) Close() error {
+	t.mu.Lock()
+	processes := make([]*backgroundProcess, 0, len(t.processes))
+	for _, p := range t.processes {
+		processes = append(processes, p)
+	}
+	t.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range processes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.stop()
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (p *backgroundProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *backgroundProcess) status() string {
+	if !p.exited() {
+		return "running"
+	}
+	if errors.Is(p.exitErr, exec.ErrWaitDelay) {
+		return "exited: status 0; output of processes it left running is not captured"
+	}
+	if p.exitErr != nil {
+		return "exited: " + p.exitErr.Error()
+	}
+	return "exited: status 0"
+}
+
+// stop terminates the process group, escalating to SIGKILL if it does not
+// exit within the grace period.
+func (p *backgroundProcess) stop() {
+	if p.exited() {
+		return
+	}
+	signalProcessGroup(p.cmd, "SIGTERM")
+	select {
+	case <-p.done:
+		return
+	case <-time.After(processStopGrace):
+	}
+	signalProcessGroup(p.cmd, "SIGKILL")
+	<-p.done
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package tool
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// setProcessGroup is a no-op: process groups are only managed on Unix.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// KillProcessGroup is a no-op: process groups are only managed on Unix.
+func KillProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup can only kill the process outside Unix.
+func signalProcessGroup(cmd *exec.Cmd, name string) error {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "KILL", "TERM", "INT":
+		return cmd.Process.Kill()
+	}
+	return fmt.Errorf("unsupported signal %s", name)
+}
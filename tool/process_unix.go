@@ -0,0 +1,53 @@
+//go:build unix
+
+package tool
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// setProcessGroup makes cmd the leader of a new process group, so that it can
+// be signalled together with the children the shell starts.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// KillProcessGroup kills the whole process group of cmd when its context is
+// cancelled, so that children started by the shell (dev servers, watchers) do
+// not outlive it.
+func KillProcessGroup(cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// signalProcessGroup sends the named signal, such as "SIGTERM" or "INT", to
+// the process group of a started cmd.
+func signalProcessGroup(cmd *exec.Cmd, name string) error {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := signals[name]
+	if !ok {
+		return fmt.Errorf("unsupported signal %s", name)
+	}
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,149 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// SandboxProfile describes how commands run by a tool are confined. Commands
+// can only write to the workspace and the extra writable paths, and get no
+// network access unless Network is set.
+type SandboxProfile struct {
+	// Workspace is the writable project directory. It defaults to the current
+	// working directory.
+	Workspace      string   `json:"workspace,omitempty"`
+	WritablePaths  []string `json:"writable_paths,omitempty"`
+	Network        bool     `json:"network"`
+	CPUSeconds     int      `json:"cpu_seconds,omitempty"`
+	MemoryMB       int      `json:"memory_mb,omitempty"`
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+}
+
+// SandboxConfig holds the named sandbox profiles and which profile each tool
+// uses. Tools without a profile run unconfined.
+type SandboxConfig struct {
+	Profiles map[string]*SandboxProfile `json:"profiles"`
+	Tools    map[string]string          `json:"tools"`
+}
+
+// DefaultSandboxPath returns the path of the sandbox configuration file. It can
+// be overridden with the TIDE_SANDBOX_FILE environment variable.
+func DefaultSandboxPath() string {
+	if path := os.Getenv("TIDE_SANDBOX_FILE"); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "tide", "sandbox.json")
+}
+
+// LoadSandboxConfig reads the sandbox configuration from path. A missing file
+// yields an empty configuration.
+func LoadSandboxConfig(path string) (*SandboxConfig, error) {
+	config := &SandboxConfig{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return config, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return &SandboxConfig{}, fmt.Errorf("invalid sandbox file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ProfileFor returns the sandbox profile selected for the named tool, or nil
+// when the tool runs unconfined.
+func (c *SandboxConfig) ProfileFor(toolName string) (*SandboxProfile, error) {
+	if c == nil {
+		return nil, nil
+	}
+	name, ok := c.Tools[toolName]
+	if !ok || name == "" || name == "none" {
+		return nil, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("tool %s uses unknown sandbox profile %q", toolName, name)
+	}
+	return profile, nil
+}
+
+// writablePaths returns the absolute paths the sandboxed command may write to.
+func (p *SandboxProfile) writablePaths() ([]string, error) {
+	workspace := p.Workspace
+	if workspace == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		workspace = wd
+	}
+
+	var paths []string
+	for _, path := range append([]string{workspace}, p.WritablePaths...) {
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("sandbox writable path: %w", err)
+		}
+		paths = append(paths, abs)
+	}
+	return paths, nil
+}
+
+// limitsScript returns the ulimit commands enforcing the profile's CPU and
+// memory limits.
+func (p *SandboxProfile) limitsScript() string {
+	var limits []string
+	if p.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", p.CPUSeconds))
+	}
+	if p.MemoryMB > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", p.MemoryMB*1024))
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return strings.Join(limits, "; ") + "; "
+}
+
+// sandboxedCommand returns a command running name with args in dir, confined
+// by sandbox when it is set.
+func sandboxedCommand(ctx context.Context, sandbox *SandboxProfile, dir, name string, args ...string) (*exec.Cmd, error) {
+	if sandbox == nil {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+		return cmd, nil
+	}
+	words := []string{shellQuote(name)}
+	for _, arg := range args {
+		words = append(words, shellQuote(arg))
+	}
+	cmd, err := sandbox.Command(ctx, strings.Join(words, " "), dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up sandbox: %w", err)
+	}
+	return cmd, nil
+}
@@ -0,0 +1,79 @@
+//go:build linux
+
+package tool
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// namespaceScript sets up the sandbox inside fresh user, mount and PID
+// namespaces: every mount is made read-only, the writable paths are bound
+// back read-write, and the command is run with the resource limits applied.
+// Arguments: $1 is the command, $2 the working directory and the rest are the
+// writable paths.
+const namespaceScript = `set -e
+mount --make-rprivate /
+for m in $(awk '{print $2}' /proc/self/mounts | sort -r); do
+	mount -o remount,bind,ro "$m" 2>/dev/null || true
+done
+mount -t proc proc /proc
+cmd="$1"; cwd="$2"; shift 2
+private_tmp=1
+for p in "$@"; do
+	case "$p" in /tmp|/tmp/*) private_tmp=0 ;; esac
+done
+if [ "$private_tmp" = 1 ]; then mount -t tmpfs tmpfs /tmp; fi
+for p in "$@"; do
+	mount --bind "$p" "$p"
+	mount -o remount,bind,rw "$p"
+done
+cd "$cwd"
+set +e
+`
+
+// Command returns a command running the shell command inside the sandbox,
+// in dir or the current directory when dir is empty. Bubblewrap is used when
+// it is installed; otherwise the sandbox is built from Linux user, mount, PID
+// and network namespaces directly.
+func (p *SandboxProfile) Command(ctx context.Context, command, dir string) (*exec.Cmd, error) {
+	writable, err := p.writablePaths()
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	limits := p.limitsScript()
+
+	if bwrap, err := exec.LookPath("bwrap"); err == nil {
+		args := []string{"--ro-bind", "/", "/", "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp"}
+		for _, path := range writable {
+			args = append(args, "--bind", path, path)
+		}
+		args = append(args, "--unshare-pid", "--die-with-parent", "--chdir", cwd)
+		if !p.Network {
+			args = append(args, "--unshare-net")
+		}
+		args = append(args, "sh", "-c", limits+`exec sh -c "$1"`, "sh", command)
+		return exec.CommandContext(ctx, bwrap, args...), nil
+	}
+
+	script := namespaceScript + limits + `exec sh -c "$cmd"`
+	args := append([]string{"-c", script, "sandbox", command, cwd}, writable...)
+	cmd := exec.CommandContext(ctx, "sh", args...)
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID)
+	if !p.Network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  flags,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	return cmd, nil
+}
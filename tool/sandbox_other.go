@@ -0,0 +1,15 @@
+//go:build !linux
+
+package tool
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Command returns an error: sandboxing relies on Linux namespaces.
+func (p *SandboxProfile) Command(ctx context.Context, command, dir string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("sandboxed execution is not supported on %s", runtime.GOOS)
+}
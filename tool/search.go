@@ -1,136 +1,95 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"net/url"
-	"os"
-	"regexp"
 	"strings"
+	"time"
+
+	"github.com/sgoal/tide/search"
+)
+
+const (
+	defaultSearchResults = 8
+	maxSearchResults     = 20
+	searchTimeout        = 30 * time.Second
 )
 
 // SearchTool is a tool for searching the web.
-type SearchTool struct{}
+type SearchTool struct {
+	// Backend answers the queries. DuckDuckGo is used when nil.
+	Backend search.Backend
+}
 
 func (t *SearchTool) Name() string {
 	return "search"
 }
 
 func (t *SearchTool) Description() string {
-	return "A tool for searching the web using DuckDuckGo."
+	if local, ok := t.backend().(*search.Local); ok {
+		return fmt.Sprintf("A tool for searching the local documentation in %s. Returns the title, path and best matching line of each file; read a result with the file_reader tool.", strings.Join(local.Dirs, ", "))
+	}
+	return fmt.Sprintf("A tool for searching the web using %s. Returns the title, URL and a snippet of each result; read a result with the web_fetch tool.", t.backend().Name())
+}
+
+func (t *SearchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {
+				"type": "string",
+				"description": "The search query."
+			},
+			"max_results": {
+				"type": "integer",
+				"description": "The most results to return, up to 20. Defaults to 8."
+			}
+		},
+		"required": ["query"]
+	}`)
 }
 
 func (t *SearchTool) Execute(args json.RawMessage) (string, error) {
 	var params struct {
-		Query string `json:"query"`
+		Query      string `json:"query"`
+		MaxResults int    `json:"max_results"`
 	}
 	if err := json.Unmarshal(args, &params); err != nil {
 		return "", fmt.Errorf("invalid arguments: %w", err)
 	}
-
-	return t.search(params.Query)
-}
-
-func (t *SearchTool) search(query string) (string, error) {
-	encodedQuery := url.QueryEscape(query)
-
-	// 使用DuckDuckGo HTML搜索端点
-	url := "https://html.duckduckgo.com/html/?q=" + encodedQuery
-
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	// 设置User-Agent避免被阻止
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
+	if strings.TrimSpace(params.Query) == "" {
+		return "", fmt.Errorf("query is required")
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return "", fmt.Errorf("search failed with status code: %d", res.StatusCode)
+	if params.MaxResults <= 0 {
+		params.MaxResults = defaultSearchResults
 	}
+	params.MaxResults = min(params.MaxResults, maxSearchResults)
 
-	body, err := ioutil.ReadAll(res.Body)
+	ctx, cancel := context.WithTimeout(context.Background(), searchTimeout)
+	defer cancel()
+	backend := t.backend()
+	results, err := backend.Search(ctx, params.Query, params.MaxResults)
 	if err != nil {
-		return "", err
-	}
-	os.WriteFile("search.html", body, 0644)
-	return t.parseHTML(body)
-}
-
-func (t *SearchTool) parseHTML(body []byte) (string, error) {
-	content := string(body)
-
-	// 简单的HTML解析，提取搜索结果
-	var results []string
-
-	// 查找搜索结果标题和描述
-	// DuckDuckGo HTML结构中的搜索结果
-	lines := strings.Split(content, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// 查找标题
-		if strings.Contains(line, "result__title") || strings.Contains(line, "result__a") {
-			// 提取标题文本
-			start := strings.Index(line, ">")
-			if start > 0 {
-				end := strings.Index(line[start+1:], "<")
-				if end > 0 {
-					title := strings.TrimSpace(line[start+1 : start+1+end])
-					if title != "" && !strings.Contains(title, "DuckDuckGo") {
-						results = append(results, title)
-					}
-				}
-			}
-		}
-
-		// 查找描述
-		if strings.Contains(line, "result__snippet") {
-			start := strings.Index(line, ">")
-			if start > 0 {
-				end := strings.Index(line[start+1:], "<")
-				if end > 0 {
-					desc := strings.TrimSpace(line[start+1 : start+1+end])
-					if desc != "" {
-						results = append(results, "  "+desc)
-					}
-				}
-			}
-		}
+		return "", fmt.Errorf("%s search failed: %w", backend.Name(), err)
 	}
-
-	// 如果没有找到结构化数据，尝试提取纯文本
 	if len(results) == 0 {
-		// 移除HTML标签
-		re := regexp.MustCompile(`<[^>]*>`)
-		cleanText := re.ReplaceAllString(content, "")
-		cleanText = strings.TrimSpace(cleanText)
+		return fmt.Sprintf("No results found for %q.", params.Query), nil
+	}
 
-		// 分割成句子并取前几行有意义的内容
-		lines := strings.Split(cleanText, "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" && len(line) > 10 && !strings.Contains(line, "DuckDuckGo") {
-				results = append(results, line)
-				if len(results) >= 5 {
-					break
-				}
-			}
+	var b strings.Builder
+	for i, result := range results {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, result.Title, result.URL)
+		if result.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", result.Snippet)
 		}
 	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
 
-	if len(results) == 0 {
-		return "No search results found. This might be due to the search query or temporary API limitations.", nil
+func (t *SearchTool) backend() search.Backend {
+	if t.Backend == nil {
+		return &search.DuckDuckGo{}
 	}
-
-	return strings.Join(results, "\n"), nil
+	return t.Backend
 }
@@ -0,0 +1,176 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellSession is a long-lived shell that runs one command at a time, so that
+// the working directory and environment carry over between commands. The end
+// of each command is detected by a marker line carrying a random nonce and
+// the command's exit code.
+type shellSession struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu     sync.Mutex
+	output bytes.Buffer
+	// notify receives a value whenever output arrives.
+	notify chan struct{}
+	// done is closed when the shell exits.
+	done chan struct{}
+}
+
+// startShellSession starts a shell reading commands from stdin, inside
+// sandbox when it is set.
+func startShellSession(sandbox *SandboxProfile) (*shellSession, error) {
+	var cmd *exec.Cmd
+	if sandbox != nil {
+		var err error
+		if cmd, err = sandbox.Command(context.Background(), "exec sh", ""); err != nil {
+			return nil, fmt.Errorf("failed to set up sandbox: %w", err)
+		}
+	} else {
+		cmd = exec.Command("sh")
+	}
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	if err := cmd.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+	writer.Close()
+
+	s := &shellSession{
+		cmd:    cmd,
+		stdin:  stdin,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go s.readOutput(reader)
+	go func() {
+		cmd.Wait()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+func (s *shellSession) readOutput(r io.ReadCloser) {
+	defer r.Close()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.mu.Lock()
+			s.output.Write(buf[:n])
+			s.mu.Unlock()
+			select {
+			case s.notify <- struct{}{}:
+			default:
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// run executes command in the session and returns its combined output and
+// exit code. If the command does not finish within timeout, an error is
+// returned and the session must be closed.
+func (s *shellSession) run(command string, timeout time.Duration) (string, int, error) {
+	nonce, err := randomNonce()
+	if err != nil {
+		return "", 0, err
+	}
+	marker := regexp.MustCompile(`\n__TIDE_DONE_` + nonce + `_(\d+)__\n`)
+
+	s.mu.Lock()
+	s.output.Reset()
+	s.mu.Unlock()
+
+	// The command is checked for syntax errors in a child shell first, since
+	// one in the session would make the shell exit or wait for more input.
+	// It then runs with eval rather than in a subshell so that cd and export
+	// persist, and reads stdin from /dev/null so it cannot swallow the
+	// marker command.
+	quoted := shellQuote(command)
+	script := fmt.Sprintf("sh -n -c %s < /dev/null && eval %s < /dev/null\nprintf '\\n__TIDE_DONE_%s_%%d__\\n' \"$?\"\n", quoted, quoted, nonce)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return "", 0, fmt.Errorf("shell session is no longer running: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		output := s.output.String()
+		s.mu.Unlock()
+		if loc := marker.FindStringSubmatchIndex(output); loc != nil {
+			code, _ := strconv.Atoi(output[loc[2]:loc[3]])
+			return output[:loc[0]], code, nil
+		}
+
+		select {
+		case <-s.notify:
+		case <-s.done:
+			s.mu.Lock()
+			output = s.output.String()
+			s.mu.Unlock()
+			return strings.TrimSuffix(output, "\n"), 0, fmt.Errorf("the shell exited")
+		case <-timer.C:
+			s.mu.Lock()
+			output = s.output.String()
+			s.mu.Unlock()
+			return output, 0, context.DeadlineExceeded
+		}
+	}
+}
+
+// Close kills the shell and every process it started.
+func (s *shellSession) Close() error {
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+	s.stdin.Close()
+	err := signalProcessGroup(s.cmd, "SIGKILL")
+	<-s.done
+	return err
+}
+
+func randomNonce() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// shellQuote quotes s for use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
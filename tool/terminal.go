@@ -1,16 +1,42 @@
 package tool
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 )
 
+// defaultTerminalTimeout bounds commands that do not set timeout_seconds, so a
+// server started in the foreground cannot hang the agent forever.
+const defaultTerminalTimeout = 120 * time.Second
+
+// envNamePattern matches the environment variable names the shell accepts.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // TerminalTool is a tool for executing terminal commands.
-type TerminalTool struct{}
+type TerminalTool struct {
+	// Sandbox confines the commands when set.
+	Sandbox *SandboxProfile
+	// Persistent runs every command in one shell session, so that the working
+	// directory and environment carry over between calls.
+	Persistent bool
+
+	mu      sync.Mutex
+	session *shellSession
+}
 
 // TerminalToolArgs represents the arguments for the TerminalTool.
 type TerminalToolArgs struct {
-	Command string `json:"command"`
+	Command        string            `json:"command"`
+	Cwd            string            `json:"cwd,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"`
 }
 
 func (t *TerminalTool) Name() string {
@@ -22,18 +48,114 @@ func (t *TerminalTool) Description() string {
 	return "A tool for executing terminal commands."
 }
 
-// Execute executes a terminal command and returns its output.
+// Execute executes a terminal command and returns its output. Output longer
+// than 30000 bytes is cut down to its head and tail, with the full output
+// saved to a file.
 func (t *TerminalTool) Execute(args json.RawMessage) (string, error) {
 	var toolArgs TerminalToolArgs
 	if err := json.Unmarshal(args, &toolArgs); err != nil {
 		return "", err
 	}
+	for key := range toolArgs.Env {
+		if !envNamePattern.MatchString(key) {
+			return "", fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+
+	timeout := defaultTerminalTimeout
+	if toolArgs.TimeoutSeconds > 0 {
+		timeout = time.Duration(toolArgs.TimeoutSeconds) * time.Second
+	}
+	if t.Sandbox != nil && t.Sandbox.TimeoutSeconds > 0 {
+		timeout = min(timeout, time.Duration(t.Sandbox.TimeoutSeconds)*time.Second)
+	}
+	if t.Persistent {
+		return t.executePersistent(toolArgs, timeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if t.Sandbox != nil {
+		var err error
+		if cmd, err = t.Sandbox.Command(ctx, toolArgs.Command, toolArgs.Cwd); err != nil {
+			return "", fmt.Errorf("failed to set up sandbox: %w", err)
+		}
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", toolArgs.Command)
+		cmd.Dir = toolArgs.Cwd
+	}
+	if len(toolArgs.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range toolArgs.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	KillProcessGroup(cmd)
+	cmd.WaitDelay = time.Second
 
-	cmd := exec.Command("sh", "-c", toolArgs.Command)
 	output, err := cmd.CombinedOutput()
+	result := truncateOutput(string(output), "terminal")
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("command timed out after %s and was killed; start long-running commands such as servers with the process tool instead", timeout)
+	}
 	if err != nil {
-		return string(output), err
+		return result, err
 	}
 
-	return string(output), nil
+	return result, nil
+}
+
+// executePersistent runs the command in the tool's shell session, starting the
+// session if needed. cwd and env change the session's state like cd and
+// export would.
+func (t *TerminalTool) executePersistent(toolArgs TerminalToolArgs, timeout time.Duration) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.session == nil {
+		session, err := startShellSession(t.Sandbox)
+		if err != nil {
+			return "", err
+		}
+		t.session = session
+	}
+
+	var script strings.Builder
+	for key, value := range toolArgs.Env {
+		fmt.Fprintf(&script, "export %s=%s\n", key, shellQuote(value))
+	}
+	if toolArgs.Cwd != "" {
+		fmt.Fprintf(&script, "cd %s && {\n%s\n}", shellQuote(toolArgs.Cwd), toolArgs.Command)
+	} else {
+		script.WriteString(toolArgs.Command)
+	}
+
+	output, code, err := t.session.run(script.String(), timeout)
+	result := truncateOutput(output, "terminal")
+	if err != nil {
+		t.session.Close()
+		t.session = nil
+		if err == context.DeadlineExceeded {
+			return result, fmt.Errorf("command timed out after %s and was killed; the shell session was restarted, so its working directory and environment were reset", timeout)
+		}
+		return result, fmt.Errorf("%v; a new shell session will be started for the next command", err)
+	}
+	if code != 0 {
+		return result, fmt.Errorf("exit status %d", code)
+	}
+	return result, nil
+}
+
+// Close ends the persistent shell session, if any.
+func (t *TerminalTool) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session == nil {
+		return nil
+	}
+	err := t.session.Close()
+	t.session = nil
+	return err
 }
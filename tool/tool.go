@@ -8,3 +8,9 @@ type Tool interface {
 	Description() string
 	Execute(args json.RawMessage) (string, error)
 }
+
+// SchemaProvider is implemented by tools that describe their own JSON schema
+// parameters instead of having them hard-coded in the agents.
+type SchemaProvider interface {
+	Parameters() json.RawMessage
+}
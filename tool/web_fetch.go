@@ -0,0 +1,350 @@
+package tool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// maxDownloadBytes is the most read from a single response.
+	maxDownloadBytes = 5 * 1024 * 1024
+	// maxDocumentBytes caps the markdown of a document, including the pages
+	// followed after the first.
+	maxDocumentBytes = 1024 * 1024
+	// webPageBytes is the size of the parts a long document is returned in.
+	webPageBytes = 20000
+	// maxFollowedPages is the most "next" pages followed in one fetch.
+	maxFollowedPages = 10
+	// defaultWebCacheTTL is how long fetched documents are reused.
+	defaultWebCacheTTL = 24 * time.Hour
+)
+
+// WebFetchTool downloads a web page and returns its main content as markdown,
+// so the agent can read documentation it found with the search tool. Long
+// documents are returned in parts, and documents are cached on disk.
+type WebFetchTool struct {
+	// Client sends the requests. A client with a 30 second timeout is used
+	// when nil.
+	Client *http.Client
+	// CacheDir holds the fetched documents. It defaults to tide/web in the
+	// user's cache directory; documents are not cached when it cannot be
+	// determined.
+	CacheDir string
+	// CacheTTL is how long a cached document is reused. Zero means a day.
+	CacheTTL time.Duration
+}
+
+// webDocument is a fetched document, as stored in the cache.
+type webDocument struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	Markdown string    `json:"markdown"`
+	Pages    []string  `json:"pages,omitempty"`
+	Fetched  time.Time `json:"fetched"`
+	// Truncated is set when the document was cut at maxDownloadBytes or
+	// maxDocumentBytes.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+func (t *WebFetchTool) Name() string {
+	return "web_fetch"
+}
+
+func (t *WebFetchTool) Description() string {
+	return "A tool for reading a web page, such as documentation found with the search tool. The main content of the page is returned as markdown without navigation and other boilerplate. Long pages are returned in parts; request the next part with 'part'. Set 'follow_next' to also read the following pages of a paginated document. Pages are cached for a day."
+}
+
+func (t *WebFetchTool) Parameters() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"url": {
+				"type": "string",
+				"description": "The http or https URL to fetch."
+			},
+			"part": {
+				"type": "integer",
+				"description": "The part of a long page to return, starting at 1. Defaults to 1."
+			},
+			"follow_next": {
+				"type": "integer",
+				"description": "How many following pages of a paginated document (linked with rel=\"next\") to append, up to 10. Defaults to 0."
+			},
+			"refresh": {
+				"type": "boolean",
+				"description": "Download the page again instead of using the cached copy."
+			}
+		},
+		"required": ["url"]
+	}`)
+}
+
+func (t *WebFetchTool) Execute(args json.RawMessage) (string, error) {
+	var params struct {
+		URL        string `json:"url"`
+		Part       int    `json:"part"`
+		FollowNext int    `json:"follow_next"`
+		Refresh    bool   `json:"refresh"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments for web_fetch tool: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(params.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("url must be an absolute http or https URL, got %q", params.URL)
+	}
+	u.Fragment = ""
+	if params.Part <= 0 {
+		params.Part = 1
+	}
+	params.FollowNext = min(max(params.FollowNext, 0), maxFollowedPages)
+
+	cachePath := t.cachePath(u.String(), params.FollowNext)
+	doc, cached := t.loadCached(cachePath)
+	if params.Refresh || !cached {
+		if doc, err = t.fetchDocument(u, params.FollowNext); err != nil {
+			return "", err
+		}
+		t.saveCached(cachePath, doc)
+	}
+	return doc.render(params.Part, cached && !params.Refresh)
+}
+
+// render formats a part of a document for the model.
+func (d *webDocument) render(part int, cached bool) (string, error) {
+	parts := splitDocument(d.Markdown, webPageBytes)
+	if part > len(parts) {
+		return "", fmt.Errorf("part %d does not exist; %s has %d parts", part, d.URL, len(parts))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "URL: %s\n", d.URL)
+	if d.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", d.Title)
+	}
+	if len(d.Pages) > 1 {
+		fmt.Fprintf(&b, "Pages: %s\n", strings.Join(d.Pages, ", "))
+	}
+	if cached {
+		fmt.Fprintf(&b, "Cached: fetched %s ago\n", time.Since(d.Fetched).Round(time.Minute))
+	}
+	if len(parts) > 1 {
+		fmt.Fprintf(&b, "Part %d of %d\n", part, len(parts))
+	}
+	b.WriteString("\n")
+	if d.Markdown == "" {
+		b.WriteString("(the page has no text content)")
+	} else {
+		b.WriteString(parts[part-1])
+	}
+	if part < len(parts) {
+		fmt.Fprintf(&b, "\n\n[Continued in part %d]", part+1)
+	} else if d.Truncated {
+		b.WriteString("\n\n[The document was too long and has been cut off here]")
+	}
+	return b.String(), nil
+}
+
+// fetchDocument downloads a page and up to follow pages it links to as the
+// next page of the document.
+func (t *WebFetchTool) fetchDocument(u *url.URL, follow int) (*webDocument, error) {
+	doc := &webDocument{URL: u.String(), Fetched: time.Now()}
+	seen := map[string]bool{}
+	var parts []string
+	size := 0
+	for next := u; next != nil && len(doc.Pages) <= follow; {
+		page, err := t.fetchPage(next)
+		if err != nil {
+			if len(doc.Pages) == 0 {
+				return nil, err
+			}
+			parts = append(parts, fmt.Sprintf("[Could not fetch the next page %s: %v]", next, err))
+			break
+		}
+		seen[next.String()] = true
+		doc.Pages = append(doc.Pages, page.url)
+		if doc.Title == "" {
+			doc.Title = page.Title
+		}
+		doc.Truncated = doc.Truncated || page.truncated
+
+		markdown := page.Markdown
+		if size+len(markdown) > maxDocumentBytes {
+			markdown = strings.ToValidUTF8(markdown[:max(maxDocumentBytes-size, 0)], "")
+			doc.Truncated = true
+		}
+		parts = append(parts, markdown)
+		size += len(markdown)
+		if doc.Truncated {
+			break
+		}
+
+		next = nil
+		if page.Next != "" && !seen[page.Next] {
+			// Only follow pages of the same site, as reached after
+			// redirects such as the one to www.
+			current, err := url.Parse(page.url)
+			if n, nerr := url.Parse(page.Next); err == nil && nerr == nil && n.Host == current.Host {
+				next = n
+			}
+		}
+	}
+	doc.Markdown = strings.Join(parts, "\n\n---\n\n")
+	return doc, nil
+}
+
+// fetchedPage is a single downloaded page.
+type fetchedPage struct {
+	htmlDocument
+	// url is the URL of the page after redirects.
+	url       string
+	truncated bool
+}
+
+func (t *WebFetchTool) fetchPage(u *url.URL) (*fetchedPage, error) {
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; tide; +https://github.com/sgoal/tide)")
+	req.Header.Set("Accept", "text/html, application/xhtml+xml, text/markdown;q=0.9, text/plain;q=0.8, */*;q=0.5")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch %s: %s", u, res.Status)
+	}
+
+	contentType := res.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	body, err := charset.NewReader(io.LimitReader(res.Body, maxDownloadBytes+1), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", u, err)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	page := &fetchedPage{url: res.Request.URL.String()}
+	if len(data) > maxDownloadBytes {
+		data = data[:maxDownloadBytes]
+		page.truncated = true
+	}
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		root, err := html.Parse(strings.NewReader(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", u, err)
+		}
+		page.htmlDocument = convertHTML(root, res.Request.URL)
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") || mediaType == "application/xml":
+		page.Markdown = strings.TrimSpace(string(data))
+	default:
+		return nil, fmt.Errorf("%s is %s, which is not a text document", u, mediaType)
+	}
+	return page, nil
+}
+
+// splitDocument splits markdown into parts of at most size bytes, at line
+// breaks where possible.
+func splitDocument(markdown string, size int) []string {
+	var parts []string
+	for len(markdown) > size {
+		cut := strings.LastIndex(markdown[:size], "\n")
+		if cut <= size/2 {
+			cut = size
+			// Do not split a UTF-8 sequence.
+			for cut > 0 && markdown[cut]&0xC0 == 0x80 {
+				cut--
+			}
+		}
+		parts = append(parts, strings.TrimRight(markdown[:cut], "\n"))
+		markdown = strings.TrimLeft(markdown[cut:], "\n")
+	}
+	return append(parts, markdown)
+}
+
+// cachePath returns the file caching a document, or "" when there is no
+// cache directory.
+func (t *WebFetchTool) cachePath(u string, follow int) string {
+	dir := t.CacheDir
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(cacheDir, "tide", "web")
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", u, follow)))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadCached returns the cached document at path if it has not expired.
+func (t *WebFetchTool) loadCached(path string) (*webDocument, bool) {
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var doc webDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+	ttl := t.CacheTTL
+	if ttl == 0 {
+		ttl = defaultWebCacheTTL
+	}
+	if time.Since(doc.Fetched) > ttl {
+		return nil, false
+	}
+	return &doc, true
+}
+
+// saveCached stores a document in the cache. Failures only cost a download
+// later, so they are ignored.
+func (t *WebFetchTool) saveCached(path string, doc *webDocument) {
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
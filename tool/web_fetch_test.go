@@ -0,0 +1,166 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fetch runs the web_fetch tool with the given arguments.
+func fetch(t *testing.T, tool *WebFetchTool, args map[string]any) string {
+	t.Helper()
+	data, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tool.Execute(data)
+	if err != nil {
+		t.Fatalf("web_fetch %v: %v", args, err)
+	}
+	return out
+}
+
+func serveHTML(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestWebFetchStripsBoilerplate(t *testing.T) {
+	server := httptest.NewServer(serveHTML(`<!DOCTYPE html>
+<html><head><title>Guide</title><script>var tracking = 1;</script></head>
+<body>
+<header><nav><a href="/">Home</a> <a href="/blog">Blog</a></nav></header>
+<div class="cookie-banner">We use cookies</div>
+<main>
+<h1>Getting started</h1>
+<p>Install the <code>tide</code> binary and run it.</p>
+<ul><li>First step</li><li>Second step</li></ul>
+</main>
+<footer>Copyright 2024</footer>
+</body></html>`))
+	defer server.Close()
+
+	out := fetch(t, &WebFetchTool{CacheDir: t.TempDir()}, map[string]any{"url": server.URL})
+	for _, want := range []string{"Title: Guide", "# Getting started", "Install the `tide` binary and run it.", "- First step", "- Second step"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Home", "Blog", "cookies", "Copyright", "tracking"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("output contains boilerplate %q:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestWebFetchFollowsNextPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/1", serveHTML(`<html><head><link rel="next" href="/2"></head><body><main><p>Page one.</p></main></body></html>`))
+	mux.HandleFunc("/2", serveHTML(`<html><body><main><p>Page two.</p><a rel="next" href="/3">Next</a></main></body></html>`))
+	mux.HandleFunc("/3", serveHTML(`<html><body><main><p>Page three.</p></main></body></html>`))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	tool := &WebFetchTool{CacheDir: t.TempDir()}
+
+	out := fetch(t, tool, map[string]any{"url": server.URL + "/1"})
+	if !strings.Contains(out, "Page one.") || strings.Contains(out, "Page two.") {
+		t.Errorf("without follow_next, only the first page should be read:\n%s", out)
+	}
+
+	out = fetch(t, tool, map[string]any{"url": server.URL + "/1", "follow_next": 1})
+	if !strings.Contains(out, "Page one.") || !strings.Contains(out, "Page two.") || strings.Contains(out, "Page three.") {
+		t.Errorf("follow_next 1 should read two pages:\n%s", out)
+	}
+	if want := fmt.Sprintf("Pages: %s/1, %s/2", server.URL, server.URL); !strings.Contains(out, want) {
+		t.Errorf("output does not list the pages %q:\n%s", want, out)
+	}
+}
+
+func TestWebFetchFollowsNextPagesAfterRedirect(t *testing.T) {
+	// The site redirects to another host, as example.com does to
+	// www.example.com; its next links point to that host.
+	site := http.NewServeMux()
+	target := httptest.NewServer(site)
+	defer target.Close()
+	site.HandleFunc("/1", serveHTML(`<html><body><main><p>Page one.</p><a rel="next" href="`+target.URL+`/2">Next</a></main></body></html>`))
+	site.HandleFunc("/2", serveHTML(`<html><body><main><p>Page two.</p></main></body></html>`))
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL+"/1", http.StatusMovedPermanently))
+	defer redirect.Close()
+
+	out := fetch(t, &WebFetchTool{CacheDir: t.TempDir()}, map[string]any{"url": redirect.URL, "follow_next": 1})
+	if !strings.Contains(out, "Page two.") {
+		t.Errorf("the next page on the redirected host was not followed:\n%s", out)
+	}
+}
+
+func TestWebFetchCapsDocumentSize(t *testing.T) {
+	paragraph := "<p>" + strings.Repeat("word ", 1000) + "</p>\n"
+	server := httptest.NewServer(serveHTML("<html><body><main>" + strings.Repeat(paragraph, maxDocumentBytes/len(paragraph)+50) + "</main></body></html>"))
+	defer server.Close()
+	tool := &WebFetchTool{CacheDir: t.TempDir()}
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := tool.fetchDocument(u, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Truncated || len(doc.Markdown) > maxDocumentBytes {
+		t.Errorf("document of %d bytes, truncated %v; want at most %d bytes, truncated", len(doc.Markdown), doc.Truncated, maxDocumentBytes)
+	}
+
+	parts := splitDocument(doc.Markdown, webPageBytes)
+	first := fetch(t, tool, map[string]any{"url": server.URL})
+	if want := fmt.Sprintf("Part 1 of %d", len(parts)); !strings.Contains(first, want) || !strings.Contains(first, "[Continued in part 2]") {
+		t.Errorf("first part does not say %q and where it continues:\n%.300s", want, first)
+	}
+	last := fetch(t, tool, map[string]any{"url": server.URL, "part": len(parts)})
+	if !strings.Contains(last, "cut off") {
+		t.Errorf("last part does not say the document was cut off:\n%.300s", last[max(len(last)-300, 0):])
+	}
+	data, _ := json.Marshal(map[string]any{"url": server.URL, "part": len(parts) + 1})
+	if _, err := tool.Execute(data); err == nil {
+		t.Error("a part past the end should be an error")
+	}
+}
+
+func TestWebFetchReusesCache(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveHTML("<html><body><main><p>Cached content.</p></main></body></html>")(w, r)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	out := fetch(t, &WebFetchTool{CacheDir: dir}, map[string]any{"url": server.URL})
+	if strings.Contains(out, "Cached:") {
+		t.Errorf("first fetch should not come from the cache:\n%s", out)
+	}
+	// A new tool with the same cache directory, as in a later session.
+	out = fetch(t, &WebFetchTool{CacheDir: dir}, map[string]any{"url": server.URL})
+	if !strings.Contains(out, "Cached:") || !strings.Contains(out, "Cached content.") {
+		t.Errorf("second fetch should come from the cache:\n%s", out)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests after a cached fetch, want 1", n)
+	}
+
+	fetch(t, &WebFetchTool{CacheDir: dir}, map[string]any{"url": server.URL, "refresh": true})
+	if n := requests.Load(); n != 2 {
+		t.Errorf("got %d requests after a refresh, want 2", n)
+	}
+	fetch(t, &WebFetchTool{CacheDir: dir, CacheTTL: -1}, map[string]any{"url": server.URL})
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d requests after the cache expired, want 3", n)
+	}
+}
@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/rivo/tview"
+)
+
+// askApproval shows question in a dialog over pages and waits for the user's
+// answer, which is also recorded in textView. It must not be called from the
+// UI goroutine.
+func askApproval(app *tview.Application, pages *tview.Pages, textView *tview.TextView, focus tview.Primitive, question string) bool {
+	answer := make(chan bool, 1)
+	app.QueueUpdateDraw(func() {
+		modal := tview.NewModal().
+			SetText("The agent asks for approval:\n\n" + question).
+			AddButtons([]string{"Deny", "Allow"}).
+			SetDoneFunc(func(_ int, label string) {
+				pages.RemovePage("approval")
+				app.SetFocus(focus)
+				allowed := label == "Allow"
+				if allowed {
+					fmt.Fprintf(textView, "[yellow]Allowed:[white] %s\n", tview.Escape(question))
+				} else {
+					fmt.Fprintf(textView, "[yellow]Denied:[white] %s\n", tview.Escape(question))
+				}
+				answer <- allowed
+			})
+		pages.AddPage("approval", modal, true, true)
+		app.SetFocus(modal)
+	})
+	return <-answer
+}
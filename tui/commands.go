@@ -0,0 +1,217 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rivo/tview"
+	"github.com/sgoal/tide/agent"
+	"github.com/sgoal/tide/tool"
+)
+
+// customCommandsDir holds user-defined slash commands. Each markdown file is a
+// prompt template; the file name (without .md) is the command name.
+const customCommandsDir = ".tide/commands"
+
+var builtinCommands = []struct {
+	name        string
+	description string
+}{
+	{"/clear", "Clear the conversation history"},
+	{"/history", "Show the conversation history"},
+	{"/model [name]", "Show or change the model"},
+	{"/tools", "List the tools available to the agent"},
+	{"/undo", "Remove the last prompt and the agent's response to it"},
+	{"/save", "Save the conversation history"},
+	{"/cost", "Show token usage and estimated cost"},
+	{"/image <path>", "Attach an image to the next prompt"},
+	{"/diagnostics", "Show errors and warnings reported by language servers"},
+	{"/help", "Show this help"},
+}
+
+// commandHandler runs the slash commands typed into the Builder Mode input.
+type commandHandler struct {
+	agent    *agent.ReActAgent
+	textView *tview.TextView
+	images   []string
+	// busy is set while the agent is processing a prompt, when the
+	// conversation must not be read or changed.
+	busy atomic.Bool
+}
+
+// historyCommands read or change the conversation, the model or the token
+// usage, which the agent uses while it processes a prompt.
+var historyCommands = map[string]bool{"/clear": true, "/history": true, "/undo": true, "/save": true, "/model": true, "/cost": true}
+
+// Handle runs input as a slash command. It returns the prompt to send to the
+// agent when input expands a custom command, and an empty string when the
+// command was handled locally.
+func (h *commandHandler) Handle(input string) string {
+	name, args, _ := strings.Cut(strings.TrimSpace(input), " ")
+	args = strings.TrimSpace(args)
+
+	if name == "/help" {
+		h.printHelp()
+		return ""
+	}
+	if h.agent == nil {
+		fmt.Fprintf(h.textView, "[red]Error:[white] the agent is not available\n")
+		return ""
+	}
+	if historyCommands[name] && h.busy.Load() {
+		fmt.Fprintf(h.textView, "[red]Error:[white] %s is not available while the agent is working\n", name)
+		return ""
+	}
+
+	switch name {
+	case "/clear":
+		h.agent.ClearHistory()
+		h.textView.Clear()
+		fmt.Fprintf(h.textView, "[gray]Conversation cleared.[white]\n")
+	case "/history":
+		for _, msg := range h.agent.GetHistory() {
+			fmt.Fprintf(h.textView, "[yellow]%s:[white] %s\n", msg.Role, tview.Escape(messageText(msg)))
+		}
+	case "/model":
+		if args != "" {
+			h.agent.SetModel(args)
+		}
+		fmt.Fprintf(h.textView, "[gray]Model:[white] %s\n", tview.Escape(h.agent.Model()))
+	case "/tools":
+		for _, t := range h.agent.Tools() {
+			fmt.Fprintf(h.textView, "[yellow]%s:[white] %s\n", t.Name(), tview.Escape(t.Description()))
+		}
+	case "/undo":
+		if h.agent.UndoLastExchange() {
+			fmt.Fprintf(h.textView, "[gray]Removed the last exchange.[white]\n")
+		} else {
+			fmt.Fprintf(h.textView, "[gray]Nothing to undo.[white]\n")
+		}
+	case "/save":
+		if err := h.agent.SaveHistory(); err != nil {
+			fmt.Fprintf(h.textView, "[red]Error:[white] %s\n", tview.Escape(err.Error()))
+		} else {
+			fmt.Fprintf(h.textView, "[gray]Conversation saved.[white]\n")
+		}
+	case "/cost":
+		usage := h.agent.Usage()
+		fmt.Fprintf(h.textView, "[gray]Tokens:[white] %d prompt, %d completion, %d total\n",
+			usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+		if cost, ok := agent.EstimateCost(h.agent.Model(), usage); ok {
+			fmt.Fprintf(h.textView, "[gray]Estimated cost:[white] $%.4f\n", cost)
+		}
+	case "/image":
+		if args == "" {
+			fmt.Fprintf(h.textView, "[red]Error:[white] usage: /image <path>\n")
+			break
+		}
+		image, err := tool.ImageDataURL(args)
+		if err != nil {
+			fmt.Fprintf(h.textView, "[red]Error:[white] %v\n", err)
+			break
+		}
+		h.images = append(h.images, image)
+		fmt.Fprintf(h.textView, "[gray]Attached %s to the next prompt.[white]\n", args)
+	case "/diagnostics":
+		files := h.agent.Diagnostics()
+		if len(files) == 0 {
+			fmt.Fprintf(h.textView, "[gray]No diagnostics.[white]\n")
+			break
+		}
+		fmt.Fprintf(h.textView, "%s\n", tview.Escape(tool.FormatDiagnostics(files, h.agent.DiagnosticsRoot())))
+	default:
+		template, err := loadCustomCommand(strings.TrimPrefix(name, "/"))
+		if err != nil {
+			fmt.Fprintf(h.textView, "[red]Error:[white] unknown command %s, type /help for a list of commands\n", name)
+			return ""
+		}
+		// A custom command sends a prompt, and only one can run at a time.
+		if h.busy.Load() {
+			fmt.Fprintf(h.textView, "[red]Error:[white] %s is not available while the agent is working\n", name)
+			return ""
+		}
+		return expandTemplate(template, args)
+	}
+	return ""
+}
+
+// TakeImages returns the images attached with /image and forgets them.
+func (h *commandHandler) TakeImages() []string {
+	images := h.images
+	h.images = nil
+	return images
+}
+
+func (h *commandHandler) printHelp() {
+	fmt.Fprintf(h.textView, "[green]Commands:[white]\n")
+	for _, c := range builtinCommands {
+		fmt.Fprintf(h.textView, "  %-16s %s\n", c.name, c.description)
+	}
+	custom := customCommands()
+	if len(custom) == 0 {
+		return
+	}
+	fmt.Fprintf(h.textView, "[green]Custom commands (%s):[white]\n", customCommandsDir)
+	for _, name := range custom {
+		template, err := loadCustomCommand(name)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h.textView, "  %-16s %s\n", "/"+name, templateSummary(template))
+	}
+}
+
+// customCommands returns the names of the user-defined commands.
+func customCommands() []string {
+	matches, _ := filepath.Glob(filepath.Join(customCommandsDir, "*.md"))
+	var names []string
+	for _, match := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(match), ".md"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func loadCustomCommand(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid command name %q", name)
+	}
+	data, err := os.ReadFile(filepath.Join(customCommandsDir, name+".md"))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// expandTemplate substitutes the command arguments into a prompt template.
+// $ARGUMENTS is replaced by the whole argument string and $1 to $9 by the
+// individual whitespace-separated arguments.
+func expandTemplate(template, args string) string {
+	fields := strings.Fields(args)
+	replacements := []string{"$ARGUMENTS", args}
+	for i := 9; i >= 1; i-- {
+		value := ""
+		if i <= len(fields) {
+			value = fields[i-1]
+		}
+		replacements = append(replacements, "$"+strconv.Itoa(i), value)
+	}
+	return strings.TrimSpace(strings.NewReplacer(replacements...).Replace(template))
+}
+
+// templateSummary returns the first non-empty line of a template, used as the
+// command's description in /help.
+func templateSummary(template string) string {
+	for _, line := range strings.Split(template, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "# "))
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
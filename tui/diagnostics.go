@@ -0,0 +1,35 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/sgoal/tide/lsp"
+)
+
+// diagnosticsSummary returns the status line text for the language server
+// diagnostics, or an empty string when there are no errors or warnings.
+func diagnosticsSummary(files []lsp.FileDiagnostics) string {
+	errors, warnings, affected := 0, 0, 0
+	for _, file := range files {
+		counted := false
+		for _, d := range file.Diagnostics {
+			switch d.SeverityName() {
+			case "error":
+				errors++
+			case "warning":
+				warnings++
+			default:
+				continue
+			}
+			counted = true
+		}
+		if counted {
+			affected++
+		}
+	}
+	if errors == 0 && warnings == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[red]%d error(s)[white], [yellow]%d warning(s)[white] in %d file(s); type /diagnostics to list them",
+		errors, warnings, affected)
+}
@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sgoal/tide/tool"
+)
+
+const (
+	maxMentionBytes       = 100 * 1024
+	maxMentionCompletions = 20
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@(\S+)`)
+
+// expandMentions appends the content of every file (or the listing of every
+// directory) mentioned as @path in prompt. Mentioned images are returned as
+// data URLs to be attached to the message instead. Mentions of paths that do
+// not exist are left as they are.
+func expandMentions(prompt string) (string, []string) {
+	var attachments, images []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(prompt, -1) {
+		path := strings.TrimRight(match[1], ".,;:!?)")
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if isImagePath(path) {
+			if image, err := tool.ImageDataURL(path); err == nil {
+				images = append(images, image)
+				continue
+			}
+		}
+		if attachment, ok := readMention(path); ok {
+			attachments = append(attachments, attachment)
+		}
+	}
+	if len(attachments) == 0 {
+		return prompt, images
+	}
+	return prompt + "\n\n" + strings.Join(attachments, "\n\n"), images
+}
+
+func isImagePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+func readMention(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return "", false
+		}
+		var names []string
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		return fmt.Sprintf("Directory listing of %s:\n%s", path, strings.Join(names, "\n")), true
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return fmt.Sprintf("%s is a binary file (%d bytes); its content was not attached.", path, len(data)), true
+	}
+	note := ""
+	if len(data) > maxMentionBytes {
+		data = data[:maxMentionBytes]
+		note = fmt.Sprintf("\n(truncated to the first %d of %d bytes)", maxMentionBytes, info.Size())
+	}
+	return fmt.Sprintf("Contents of %s:\n```\n%s\n```%s", path, strings.TrimRight(string(data), "\n"), note), true
+}
+
+// completeMention is the autocomplete function of the Builder Mode input. When
+// the word being typed starts with @, it offers the matching paths, each as the
+// complete input text with that word replaced.
+func completeMention(text string) []string {
+	start := strings.LastIndexAny(text, " \t") + 1
+	word := text[start:]
+	if !strings.HasPrefix(word, "@") {
+		return nil
+	}
+	partial := word[1:]
+
+	dir, prefix := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var completions []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		completions = append(completions, text[:start]+"@"+dir+name)
+	}
+	if len(completions) == 1 && completions[0] == text {
+		return nil
+	}
+	sort.Strings(completions)
+	if len(completions) > maxMentionCompletions {
+		completions = completions[:maxMentionCompletions]
+	}
+	return completions
+}
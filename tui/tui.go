@@ -2,20 +2,27 @@ package tui
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	openaai "github.com/sashabaranov/go-openai"
 	"github.com/sgoal/tide/agent"
 )
 
 func NewTUI() {
 	app := tview.NewApplication()
 
+	// Sessions to end when the application exits
+	var sessions []io.Closer
+
 	// Create a form for mode selection
 	form := tview.NewForm().
 		AddButton("Builder Mode", func() {
-			showBuilderMode(app)
+			if session := showBuilderMode(app); session != nil {
+				sessions = append(sessions, session)
+			}
 		}).
 		AddButton("SOLO Mode", func() {
 			showSoloMode(app)
@@ -26,12 +33,18 @@ func NewTUI() {
 
 	form.SetBorder(true).SetTitle("Select a mode").SetTitleAlign(tview.AlignCenter)
 
-	if err := app.SetRoot(form, true).EnableMouse(true).Run(); err != nil {
+	err := app.SetRoot(form, true).EnableMouse(true).Run()
+	for _, session := range sessions {
+		session.Close()
+	}
+	if err != nil {
 		panic(err)
 	}
 }
 
-func showBuilderMode(app *tview.Application) {
+// showBuilderMode switches to Builder Mode and returns the agent session, or
+// nil if the agent could not be created.
+func showBuilderMode(app *tview.Application) io.Closer {
 	textView := tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true).
@@ -41,9 +54,12 @@ func showBuilderMode(app *tview.Application) {
 	textView.ScrollToEnd()
 	inputField := tview.NewInputField().
 		SetLabel("> ").
-		SetFieldWidth(0)
+		SetFieldWidth(0).
+		SetAutocompleteFunc(completeMention)
+	// status shows the language server diagnostics for the workspace
+	status := tview.NewTextView().SetDynamicColors(true)
 
-	agent, err := agent.NewReActAgent(textView)
+	agent, err := agent.NewReActAgent(escapeWriter{textView})
 	if err != nil {
 		app.QueueUpdateDraw(func() {
 			fmt.Fprintf(textView, "[red]Error:[white] %v\n", err)
@@ -54,10 +70,16 @@ func showBuilderMode(app *tview.Application) {
 			fmt.Fprintf(textView, "Error loading history: %v\n", err)
 		}
 		for _, msg := range agent.GetHistory() {
-			fmt.Fprintf(textView, "[yellow]%s:[white] %s\n", msg.Role, msg.Content)
+			fmt.Fprintf(textView, "[yellow]%s:[white] %s\n", msg.Role, tview.Escape(messageText(msg)))
 		}
 		textView.ScrollToEnd()
+		agent.OnDiagnostics(func() {
+			app.QueueUpdateDraw(func() {
+				status.SetText(diagnosticsSummary(agent.Diagnostics()))
+			})
+		})
 	}
+	commands := &commandHandler{agent: agent, textView: textView}
 
 	inputField.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
@@ -67,15 +89,29 @@ func showBuilderMode(app *tview.Application) {
 			}
 			inputField.SetText("")
 			textView.ScrollToEnd()
+			if commands.busy.Load() && !strings.HasPrefix(strings.TrimSpace(prompt), "/") {
+				fmt.Fprintf(textView, "[gray]Wait for the agent to finish before sending another prompt.[white]\n")
+				inputField.SetText(prompt)
+				return
+			}
+			if strings.HasPrefix(strings.TrimSpace(prompt), "/") {
+				if prompt = commands.Handle(prompt); prompt == "" {
+					return
+				}
+			}
+			prompt, images := expandMentions(prompt)
+			images = append(commands.TakeImages(), images...)
+			commands.busy.Store(true)
 			go func() {
-				response, err := agent.ProcessCommand(prompt)
+				defer commands.busy.Store(false)
+				response, err := agent.ProcessCommand(prompt, images...)
 				if err != nil {
 					app.QueueUpdateDraw(func() {
-						fmt.Fprintf(textView, "[red]Error:[white] %v\n", err)
+						fmt.Fprintf(textView, "[red]Error:[white] %s\n", tview.Escape(err.Error()))
 					})
 				} else {
 					app.QueueUpdateDraw(func() {
-						fmt.Fprintf(textView, "[green]Agent:[white] %s\n", response)
+						fmt.Fprintf(textView, "[green]Agent:[white] %s\n", tview.Escape(response))
 					})
 				}
 			}()
@@ -85,9 +121,51 @@ func showBuilderMode(app *tview.Application) {
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(textView, 0, 1, false).
+		AddItem(status, 1, 0, false).
 		AddItem(inputField, 3, 0, true)
+	// pages shows approval dialogs over the conversation
+	pages := tview.NewPages().AddPage("main", flex, true, true)
 
-	app.SetRoot(flex, true)
+	app.SetRoot(pages, true)
+
+	if agent == nil {
+		return nil
+	}
+	agent.OnApproval(func(question string) bool {
+		return askApproval(app, pages, textView, inputField, question)
+	})
+	return agent
+}
+
+// escapeWriter writes plain text, such as the agent's log, to a text view
+// with dynamic colors, so that square brackets in it are not taken as color
+// tags.
+type escapeWriter struct {
+	w io.Writer
+}
+
+func (w escapeWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, tview.Escape(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// messageText returns the text of a history message, with a placeholder for
+// every attached image.
+func messageText(msg openaai.ChatCompletionMessage) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	var parts []string
+	for _, part := range msg.MultiContent {
+		if part.Type == openaai.ChatMessagePartTypeImageURL {
+			parts = append(parts, "(image)")
+		} else {
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 func showSoloMode(app *tview.Application) {
@@ -102,7 +180,7 @@ func showSoloMode(app *tview.Application) {
 		SetLabel("Enter your project requirement: ").
 		SetFieldWidth(0)
 
-	soloAgent, err := agent.NewSoloAgent(textView)
+	soloAgent, err := agent.NewSoloAgent(escapeWriter{textView})
 	if err != nil {
 		app.QueueUpdateDraw(func() {
 			fmt.Fprintf(textView, "[red]Error:[white] %v\n", err)